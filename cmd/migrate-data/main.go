@@ -0,0 +1,112 @@
+// Command migrate-data copies the links from one storage to another, e.g.
+//
+//	migrate-data -from file -from-path links.json -to db -to-dsn "host=localhost ..."
+//
+// Links are copied with their options, variants and click counters, but without their
+// edit history, API keys or quota usage. It exits with status 1 when a link could not be
+// copied.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+	"github.com/with0p/golang-url-shortener.git/internal/migration"
+	"github.com/with0p/golang-url-shortener.git/internal/storage"
+)
+
+func main() {
+	var from, fromPath, fromDSN, to, toPath, toDSN string
+	var batchSize int
+
+	flag.StringVar(&from, "from", "file", "storage to copy the links from: file or db")
+	flag.StringVar(&fromPath, "from-path", os.Getenv("FILE_STORAGE_PATH"), "path of the file storage to copy from")
+	flag.StringVar(&fromDSN, "from-dsn", "", "address of the database to copy from")
+	flag.StringVar(&to, "to", "db", "storage to copy the links to: file or db")
+	flag.StringVar(&toPath, "to-path", "", "path of the file storage to copy to")
+	flag.StringVar(&toDSN, "to-dsn", os.Getenv("DATABASE_DSN"), "address of the database to copy to")
+	flag.IntVar(&batchSize, "batch-size", migration.DefaultBatchSize, "links read at a time")
+	flag.Parse()
+
+	if err := run(from, fromPath, fromDSN, to, toPath, toDSN, batchSize); err != nil {
+		logger.LogError(err)
+		os.Exit(1)
+	}
+}
+
+func run(from, fromPath, fromDSN, to, toPath, toDSN string, batchSize int) error {
+	ctx := context.Background()
+
+	source, closeSource, err := openStorage(ctx, from, fromPath, fromDSN, true)
+	if err != nil {
+		return fmt.Errorf("cannot open the source: %w", err)
+	}
+	defer closeSource()
+
+	destination, closeDestination, err := openStorage(ctx, to, toPath, toDSN, false)
+	if err != nil {
+		return fmt.Errorf("cannot open the destination: %w", err)
+	}
+	defer closeDestination()
+
+	report, err := migration.Migrate(ctx, source, destination, batchSize)
+	fmt.Println(report)
+
+	for _, key := range report.Conflicts {
+		fmt.Printf("conflict: %s is another link in the destination\n", key)
+	}
+	for _, failure := range report.Failures {
+		fmt.Printf("failed: %s: %v\n", failure.ShortURLKey, failure.Err)
+	}
+	for _, key := range report.Missing {
+		fmt.Printf("missing: %s\n", key)
+	}
+
+	if err != nil {
+		return err
+	}
+	if !report.OK() {
+		return errors.New("not every link has been copied")
+	}
+	return nil
+}
+
+// openStorage opens the storage of the kind. The file storage creates a missing file,
+// which is only wanted for the destination: a mistyped source path would copy nothing.
+func openStorage(ctx context.Context, kind string, path string, dsn string, mustExist bool) (storage.Storage, func(), error) {
+	switch kind {
+	case "file":
+		if path == "" {
+			return nil, nil, errors.New("file storage path is not set")
+		}
+		if mustExist {
+			if _, err := os.Stat(path); err != nil {
+				return nil, nil, err
+			}
+		}
+		fileStorage, err := storage.NewLocalFileStorage(path)
+		return fileStorage, func() {}, err
+	case "db":
+		if dsn == "" {
+			return nil, nil, errors.New("database address is not set")
+		}
+		db, err := sql.Open("pgx", dsn)
+		if err != nil {
+			return nil, nil, err
+		}
+		dbStorage, err := storage.NewDBStorage(ctx, db)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return dbStorage, func() { db.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage %q, use file or db", kind)
+	}
+}
@@ -19,5 +19,5 @@ func InitWithDBStorage(ctx context.Context, config *config.Config, db *sql.DB) (
 		return nil, errors.New("cannot init db storage")
 	}
 	logger.LogInfo(fmt.Sprintf(`DB address: %s`, config.DataBaseAddress))
-	return runInit(storage, config), nil
+	return runInit(storage, config)
 }
@@ -7,6 +7,6 @@ import (
 )
 
 func InitWithInMemoryStorage(config *config.Config) (*handler.URLHandler, error) {
-	inMemoryStorage := storage.NewInMemoryStorage(map[string]string{})
-	return runInit(inMemoryStorage, config), nil
+	inMemoryStorage := storage.NewInMemoryStorage(storage.URLStorageMap{})
+	return runInit(inMemoryStorage, config)
 }
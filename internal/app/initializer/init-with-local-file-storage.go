@@ -18,5 +18,5 @@ func InitWithLocalFileStorage(config *config.Config) (*handler.URLHandler, error
 		return nil, errors.New("cannot init local file storage")
 	}
 	logger.LogInfo(fmt.Sprintf(`File storage path: %s`, config.FileStoragePath))
-	return runInit(storage, config), nil
+	return runInit(storage, config)
 }
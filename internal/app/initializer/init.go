@@ -1,6 +1,10 @@
 package initializer
 
 import (
+	"context"
+	"fmt"
+
+	"github.com/with0p/golang-url-shortener.git/internal/auth"
 	"github.com/with0p/golang-url-shortener.git/internal/config"
 	"github.com/with0p/golang-url-shortener.git/internal/handler"
 	"github.com/with0p/golang-url-shortener.git/internal/service"
@@ -11,9 +15,19 @@ func InitConfig() *config.Config {
 	return config.GetConfig()
 }
 
-func runInit(storage storage.Storage, config *config.Config) *handler.URLHandler {
-	service := service.NewShortURLService(storage, config.ShortURL)
-	urlHandler := handler.NewURLHandler(service)
+func runInit(storage storage.Storage, config *config.Config) (*handler.URLHandler, error) {
+	// Without a configured secret the cookies are signed with one kept by the storage,
+	// users would lose their links on a restart otherwise.
+	if config.AuthSecret == "" && config.JWTAlgorithm == "" {
+		secret, err := storage.LoadOrStoreAuthSecret(context.Background(), auth.NewSecret())
+		if err != nil {
+			return nil, fmt.Errorf("cannot load auth secret: %w", err)
+		}
+		withSecret := *config
+		withSecret.AuthSecret = secret
+		config = &withSecret
+	}
 
-	return urlHandler
+	service := service.NewShortURLService(storage, config)
+	return handler.NewURLHandler(service, config)
 }
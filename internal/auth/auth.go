@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+)
+
+const (
+	cookieName   = "user_id"
+	cookieMaxAge = 365 * 24 * 60 * 60
+)
+
+// Scopes of the API keys, users authenticated by cookie have them all.
+const (
+	ScopeCreate    = "create"
+	ScopeReadStats = "read-stats"
+	ScopeDelete    = "delete"
+)
+
+var Scopes = []string{ScopeCreate, ScopeReadStats, ScopeDelete}
+
+type contextKey struct{}
+
+// Identity is the authenticated client of the request.
+type Identity struct {
+	UserID string
+	// Issued is set when the user has been created by this request, the client did
+	// not present an identity of its own.
+	Issued bool
+	// APIKeyID is set when the client authenticated with an API key, limited to Scopes.
+	APIKeyID string
+	Scopes   []string
+}
+
+func (identity Identity) HasScope(scope string) bool {
+	return identity.APIKeyID == "" || slices.Contains(identity.Scopes, scope)
+}
+
+// APIKeyResolver returns the identity of the owner of the API key.
+type APIKeyResolver func(ctx context.Context, key string) (Identity, error)
+
+// Authenticator identifies users by an API key, a bearer JWT or a signed cookie, new
+// users get a cookie on their first request.
+type Authenticator struct {
+	secret  []byte
+	jwt     *JWT
+	apiKeys APIKeyResolver
+}
+
+// NewAuthenticator signs cookies with the secret. Without a secret a random one is
+// used, cookies are then invalidated by a restart. API keys are rejected when apiKeys
+// is nil.
+func NewAuthenticator(secret string, apiKeys APIKeyResolver) *Authenticator {
+	if secret == "" {
+		logger.LogInfo("auth secret is not configured, user cookies will not survive a restart")
+		secret = NewSecret()
+	}
+
+	return &Authenticator{secret: []byte(secret), apiKeys: apiKeys}
+}
+
+// NewSecret returns a random secret to sign cookies with.
+func NewSecret() string {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return hex.EncodeToString(key)
+}
+
+// NewJWTAuthenticator uses JWTs instead of signed user ids, both in cookies and in
+// bearer tokens. Users are only created when the JWT can issue tokens.
+func NewJWTAuthenticator(jwt *JWT, apiKeys APIKeyResolver) *Authenticator {
+	return &Authenticator{jwt: jwt, apiKeys: apiKeys}
+}
+
+func (authenticator *Authenticator) HandleWithAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// A client presenting a credential is never taken for a cookie user, a wrong one
+		// must not silently create a new user.
+		if credential, isToken := credentialFromRequest(r); credential != "" {
+			identity, err := authenticator.resolveCredential(r.Context(), credential, isToken)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			handler.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+			return
+		}
+
+		identity := Identity{}
+
+		if cookie, err := r.Cookie(cookieName); err == nil {
+			identity.UserID, _ = authenticator.verifyToken(cookie.Value)
+		}
+
+		if identity.UserID == "" {
+			userID := uuid.New().String()
+			token, maxAge, err := authenticator.issueToken(userID)
+			if err != nil {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			identity = Identity{UserID: userID, Issued: true}
+			http.SetCookie(w, &http.Cookie{
+				Name:     cookieName,
+				Value:    token,
+				Path:     "/",
+				MaxAge:   maxAge,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		handler.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+	}
+}
+
+func (authenticator *Authenticator) resolveCredential(ctx context.Context, credential string, isToken bool) (Identity, error) {
+	// API keys are opaque, a bearer credential shaped like a JWT is taken for one in
+	// JWT mode.
+	if isToken && authenticator.jwt != nil && strings.Count(credential, ".") == 2 {
+		subject, err := authenticator.jwt.Verify(credential)
+		if err != nil {
+			return Identity{}, err
+		}
+		return Identity{UserID: subject}, nil
+	}
+
+	if authenticator.apiKeys == nil {
+		return Identity{}, errors.New("API keys are not supported")
+	}
+
+	identity, err := authenticator.apiKeys(ctx, credential)
+	if err != nil || identity.UserID == "" {
+		return Identity{}, errors.New("invalid API key")
+	}
+	return identity, nil
+}
+
+// issueToken returns the cookie value identifying the user and its lifetime in seconds,
+// an error when users cannot be created.
+func (authenticator *Authenticator) issueToken(userID string) (string, int, error) {
+	if authenticator.jwt == nil {
+		return authenticator.sign(userID), cookieMaxAge, nil
+	}
+
+	if !authenticator.jwt.CanIssue() {
+		return "", 0, errors.New("JWTs are verified only")
+	}
+
+	token, err := authenticator.jwt.Issue(userID)
+	if err != nil {
+		logger.LogError(err)
+		return "", 0, err
+	}
+	return token, int(authenticator.jwt.ttl.Seconds()), nil
+}
+
+func (authenticator *Authenticator) verifyToken(value string) (string, bool) {
+	if authenticator.jwt == nil {
+		return authenticator.verify(value)
+	}
+
+	subject, err := authenticator.jwt.Verify(value)
+	return subject, err == nil
+}
+
+// RequireScope forbids the requests of API keys without the scope. It has to run after
+// HandleWithAuth.
+func RequireScope(scope string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(handler http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if identity, ok := IdentityFromContext(r.Context()); ok && !identity.HasScope(scope) {
+				http.Error(w, "API key lacks the \""+scope+"\" scope", http.StatusForbidden)
+				return
+			}
+			handler(w, r)
+		}
+	}
+}
+
+// credentialFromRequest returns the API key or the bearer token of the request, isToken
+// tells it came as a bearer token.
+func credentialFromRequest(r *http.Request) (credential string, isToken bool) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key, false
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token), true
+	}
+
+	return "", false
+}
+
+func (authenticator *Authenticator) sign(userID string) string {
+	return userID + "." + hex.EncodeToString(authenticator.mac(userID))
+}
+
+func (authenticator *Authenticator) verify(value string) (string, bool) {
+	userID, signature, ok := strings.Cut(value, ".")
+	if !ok || userID == "" {
+		return "", false
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, authenticator.mac(userID)) {
+		return "", false
+	}
+
+	return userID, true
+}
+
+func (authenticator *Authenticator) mac(userID string) []byte {
+	mac := hmac.New(sha256.New, authenticator.secret)
+	mac.Write([]byte(userID))
+	return mac.Sum(nil)
+}
+
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(Identity)
+	return identity, ok && identity.UserID != ""
+}
+
+func UserIDFromContext(ctx context.Context) string {
+	identity, _ := IdentityFromContext(ctx)
+	return identity.UserID
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleWithAuth(t *testing.T) {
+	authenticator := NewAuthenticator("secret", nil)
+
+	var identity Identity
+	handler := authenticator.HandleWithAuth(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ = IdentityFromContext(r.Context())
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	handler(w, request)
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, identity.Issued)
+	assert.NotEmpty(t, identity.UserID)
+	userID := identity.UserID
+
+	tests := []struct {
+		name        string
+		cookieValue string
+		sameUser    bool
+	}{
+		{
+			name:        "Check signed cookie keeps the user",
+			cookieValue: cookies[0].Value,
+			sameUser:    true,
+		},
+		{
+			name:        "Check tampered user id gets a new user",
+			cookieValue: "other" + cookies[0].Value[len(userID):],
+		},
+		{
+			name:        "Check cookie signed with another secret gets a new user",
+			cookieValue: NewAuthenticator("another", nil).sign(userID),
+		},
+		{
+			name:        "Check cookie without signature gets a new user",
+			cookieValue: userID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.AddCookie(&http.Cookie{Name: cookieName, Value: tt.cookieValue})
+			w := httptest.NewRecorder()
+			handler(w, request)
+
+			assert.Equal(t, tt.sameUser, identity.UserID == userID)
+			assert.Equal(t, !tt.sameUser, identity.Issued)
+			assert.Equal(t, !tt.sameUser, len(w.Result().Cookies()) == 1)
+		})
+	}
+}
+
+func TestHandleWithAPIKey(t *testing.T) {
+	authenticator := NewAuthenticator("secret", func(ctx context.Context, key string) (Identity, error) {
+		if key != "valid" {
+			return Identity{}, errors.New("unknown key")
+		}
+		return Identity{UserID: "owner", APIKeyID: "key", Scopes: []string{ScopeCreate}}, nil
+	})
+
+	var identity Identity
+	handler := authenticator.HandleWithAuth(RequireScope(ScopeCreate)(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ = IdentityFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		status int
+	}{
+		{
+			name:   "Check bearer key",
+			header: "Authorization",
+			value:  "Bearer valid",
+			status: http.StatusOK,
+		},
+		{
+			name:   "Check X-API-Key header",
+			header: "X-API-Key",
+			value:  "valid",
+			status: http.StatusOK,
+		},
+		{
+			name:   "Check unknown key is not taken for a new user",
+			header: "X-API-Key",
+			value:  "invalid",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity = Identity{}
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set(tt.header, tt.value)
+			w := httptest.NewRecorder()
+			handler(w, request)
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.status, res.StatusCode)
+			assert.Empty(t, res.Cookies())
+			if tt.status == http.StatusOK {
+				assert.Equal(t, "owner", identity.UserID)
+				assert.False(t, identity.Issued)
+			}
+		})
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("X-API-Key", "valid")
+	w := httptest.NewRecorder()
+	authenticator.HandleWithAuth(RequireScope(ScopeDelete)(func(w http.ResponseWriter, r *http.Request) {}))(w, request)
+	assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+}
@@ -0,0 +1,321 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+)
+
+const (
+	// Clock skew tolerated between the issuer and the service.
+	jwtLeeway     = 30 * time.Second
+	defaultJWTTTL = cookieMaxAge * time.Second
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type JWTOptions struct {
+	Algorithm string
+	// SecretFile holds the HS256 secret.
+	SecretFile string
+	// PrivateKeyFile holds the PEM RS256 key issuing tokens, without it RS256 tokens
+	// are only verified.
+	PrivateKeyFile string
+	PublicKeyFile  string
+	Issuer         string
+	Audience       string
+	TTL            time.Duration
+}
+
+// JWT issues and verifies the tokens identifying users, the subject is the user id.
+type JWT struct {
+	algorithm  string
+	secret     []byte
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+	issuer     string
+	audience   string
+	ttl        time.Duration
+	now        func() time.Time
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+}
+
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+}
+
+// audience is a single string or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+func (a audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func NewJWT(options JWTOptions) (*JWT, error) {
+	jwt := &JWT{
+		algorithm: options.Algorithm,
+		issuer:    options.Issuer,
+		audience:  options.Audience,
+		ttl:       options.TTL,
+		now:       time.Now,
+	}
+
+	if jwt.ttl <= 0 {
+		jwt.ttl = defaultJWTTTL
+	}
+
+	switch options.Algorithm {
+	case AlgorithmHS256:
+		secret, err := os.ReadFile(options.SecretFile)
+		if err != nil {
+			return nil, err
+		}
+		jwt.secret = []byte(strings.TrimSpace(string(secret)))
+		if len(jwt.secret) == 0 {
+			return nil, errors.New("JWT secret is empty")
+		}
+	case AlgorithmRS256:
+		if options.PrivateKeyFile != "" {
+			privateKey, err := readRSAPrivateKey(options.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			jwt.privateKey = privateKey
+			jwt.publicKey = &privateKey.PublicKey
+		}
+		if options.PublicKeyFile != "" {
+			publicKey, err := readRSAPublicKey(options.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			jwt.publicKey = publicKey
+		}
+		if jwt.publicKey == nil {
+			return nil, errors.New("RS256 needs a public or a private key")
+		}
+	default:
+		return nil, fmt.Errorf("JWT algorithm %q is not supported", options.Algorithm)
+	}
+
+	return jwt, nil
+}
+
+// CanIssue tells whether tokens can be signed, an RS256 JWT with a public key only
+// verifies them.
+func (jwt *JWT) CanIssue() bool {
+	return jwt.algorithm == AlgorithmHS256 || jwt.privateKey != nil
+}
+
+func (jwt *JWT) Issue(subject string) (string, error) {
+	now := jwt.now()
+
+	claims := jwtClaims{
+		Subject:   subject,
+		Issuer:    jwt.issuer,
+		ExpiresAt: now.Add(jwt.ttl).Unix(),
+		IssuedAt:  now.Unix(),
+	}
+	if jwt.audience != "" {
+		claims.Audience = audience{jwt.audience}
+	}
+
+	header, err := json.Marshal(jwtHeader{Algorithm: jwt.algorithm, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	signature, err := jwt.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Verify checks the signature, the expiry, the issuer and the audience of the token
+// and returns its subject.
+func (jwt *JWT) Verify(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidToken
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", ErrInvalidToken
+	}
+
+	// The algorithm is fixed by the configuration, never taken from the token.
+	if header.Algorithm != jwt.algorithm {
+		return "", ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !jwt.verifySignature([]byte(parts[0]+"."+parts[1]), signature) {
+		return "", ErrInvalidToken
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", ErrInvalidToken
+	}
+
+	now := jwt.now()
+	switch {
+	case claims.Subject == "":
+		return "", fmt.Errorf("%w: no subject", ErrInvalidToken)
+	case claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(jwtLeeway)):
+		return "", fmt.Errorf("%w: expired", ErrInvalidToken)
+	case claims.NotBefore != 0 && now.Add(jwtLeeway).Before(time.Unix(claims.NotBefore, 0)):
+		return "", fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	case jwt.issuer != "" && claims.Issuer != jwt.issuer:
+		return "", fmt.Errorf("%w: wrong issuer", ErrInvalidToken)
+	case jwt.audience != "" && !slices.Contains(claims.Audience, jwt.audience):
+		return "", fmt.Errorf("%w: wrong audience", ErrInvalidToken)
+	}
+
+	return claims.Subject, nil
+}
+
+func (jwt *JWT) sign(data []byte) ([]byte, error) {
+	if jwt.algorithm == AlgorithmHS256 {
+		mac := hmac.New(sha256.New, jwt.secret)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	}
+
+	if jwt.privateKey == nil {
+		return nil, errors.New("no private key to sign tokens")
+	}
+
+	digest := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, jwt.privateKey, crypto.SHA256, digest[:])
+}
+
+func (jwt *JWT) verifySignature(data []byte, signature []byte) bool {
+	if jwt.algorithm == AlgorithmHS256 {
+		expected, _ := jwt.sign(data)
+		return hmac.Equal(expected, signature)
+	}
+
+	digest := sha256.Sum256(data)
+	return rsa.VerifyPKCS1v15(jwt.publicKey, crypto.SHA256, digest[:], signature) == nil
+}
+
+func decodeSegment(segment string, target any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+	return block, nil
+}
+
+func readRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an RSA key", path)
+	}
+	return rsaKey, nil
+}
+
+func readRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if block.Type == "CERTIFICATE" {
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		block.Bytes, _ = x509.MarshalPKIXPublicKey(certificate.PublicKey)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an RSA key", path)
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.Nil(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+func TestJWTVerify(t *testing.T) {
+	secretFile := writeFile(t, "secret", []byte("secret\n"))
+	options := JWTOptions{
+		Algorithm:  AlgorithmHS256,
+		SecretFile: secretFile,
+		Issuer:     "shortener",
+		Audience:   "api",
+		TTL:        time.Hour,
+	}
+
+	jwt, err := NewJWT(options)
+	require.Nil(t, err)
+
+	token, err := jwt.Issue("user")
+	require.Nil(t, err)
+
+	subject, err := jwt.Verify(token)
+	require.Nil(t, err)
+	assert.Equal(t, "user", subject)
+
+	issueWith := func(change func(options *JWTOptions)) string {
+		changed := options
+		change(&changed)
+		other, err := NewJWT(changed)
+		require.Nil(t, err)
+		token, err := other.Issue("user")
+		require.Nil(t, err)
+		return token
+	}
+
+	expired, err := NewJWT(options)
+	require.Nil(t, err)
+	expired.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+	expiredToken, err := expired.Issue("user")
+	require.Nil(t, err)
+
+	parts := strings.Split(token, ".")
+	noneHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{
+			name:  "Check expired token",
+			token: expiredToken,
+		},
+		{
+			name:  "Check wrong issuer",
+			token: issueWith(func(options *JWTOptions) { options.Issuer = "other" }),
+		},
+		{
+			name:  "Check wrong audience",
+			token: issueWith(func(options *JWTOptions) { options.Audience = "other" }),
+		},
+		{
+			name:  "Check wrong secret",
+			token: issueWith(func(options *JWTOptions) { options.SecretFile = writeFile(t, "other", []byte("other")) }),
+		},
+		{
+			name:  "Check unsigned token",
+			token: noneHeader + "." + parts[1] + ".",
+		},
+		{
+			name:  "Check tampered claims",
+			token: parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","exp":9999999999}`)) + "." + parts[2],
+		},
+		{
+			name:  "Check malformed token",
+			token: "not-a-token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := jwt.Verify(tt.token)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestJWTRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	privateKeyFile := writeFile(t, "private.pem", pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.Nil(t, err)
+	publicKeyFile := writeFile(t, "public.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}))
+
+	issuer, err := NewJWT(JWTOptions{Algorithm: AlgorithmRS256, PrivateKeyFile: privateKeyFile})
+	require.Nil(t, err)
+	assert.True(t, issuer.CanIssue())
+
+	verifier, err := NewJWT(JWTOptions{Algorithm: AlgorithmRS256, PublicKeyFile: publicKeyFile})
+	require.Nil(t, err)
+	assert.False(t, verifier.CanIssue())
+
+	token, err := issuer.Issue("user")
+	require.Nil(t, err)
+
+	subject, err := verifier.Verify(token)
+	require.Nil(t, err)
+	assert.Equal(t, "user", subject)
+
+	hs256, err := NewJWT(JWTOptions{Algorithm: AlgorithmHS256, SecretFile: publicKeyFile})
+	require.Nil(t, err)
+	hs256Token, err := hs256.Issue("admin")
+	require.Nil(t, err)
+
+	_, err = verifier.Verify(hs256Token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	authenticator := NewJWTAuthenticator(verifier, nil)
+	var identity Identity
+	handler := authenticator.HandleWithAuth(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ = IdentityFromContext(r.Context())
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handler(w, request)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Equal(t, "user", identity.UserID)
+
+	identity = Identity{}
+	request = httptest.NewRequest(http.MethodGet, "/", nil)
+	w = httptest.NewRecorder()
+	handler(w, request)
+	assert.Empty(t, w.Result().Cookies())
+	assert.Empty(t, identity.UserID)
+}
+
+func TestJWTAuthenticatorIssuesCookie(t *testing.T) {
+	jwt, err := NewJWT(JWTOptions{Algorithm: AlgorithmHS256, SecretFile: writeFile(t, "secret", []byte("secret"))})
+	require.Nil(t, err)
+
+	var identity Identity
+	handler := NewJWTAuthenticator(jwt, nil).HandleWithAuth(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ = IdentityFromContext(r.Context())
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	handler(w, request)
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, identity.Issued)
+
+	subject, err := jwt.Verify(cookies[0].Value)
+	require.Nil(t, err)
+	assert.Equal(t, identity.UserID, subject)
+
+	userID := identity.UserID
+	request = httptest.NewRequest(http.MethodGet, "/", nil)
+	request.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	handler(w, request)
+	assert.Equal(t, userID, identity.UserID)
+	assert.False(t, identity.Issued)
+
+	request = httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", "Bearer "+cookies[0].Value+"x")
+	w = httptest.NewRecorder()
+	handler(w, request)
+	assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+}
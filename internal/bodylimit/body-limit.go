@@ -0,0 +1,40 @@
+package bodylimit
+
+import (
+	"net/http"
+
+	"github.com/with0p/golang-url-shortener.git/internal/compressor/gzip"
+)
+
+// Limiter caps the request bodies, reading past a limit fails with *http.MaxBytesError.
+type Limiter struct {
+	maxBodySize         int64
+	maxDecompressedSize int64
+}
+
+// NewLimiter limits the body as sent and, for gzipped bodies, as decompressed. A zero
+// size disables its limit.
+func NewLimiter(maxBodySize int64, maxDecompressedSize int64) *Limiter {
+	return &Limiter{
+		maxBodySize:         maxBodySize,
+		maxDecompressedSize: maxDecompressedSize,
+	}
+}
+
+func (limiter *Limiter) HandleWithBodyLimit(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if limiter.maxBodySize > 0 {
+			if r.ContentLength > limiter.maxBodySize {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limiter.maxBodySize)
+		}
+
+		if limiter.maxDecompressedSize > 0 {
+			r = r.WithContext(compressor.WithMaxDecompressedSize(r.Context(), limiter.maxDecompressedSize))
+		}
+
+		handler(w, r)
+	}
+}
@@ -0,0 +1,99 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+)
+
+type Resolver struct {
+	trustedProxies []*net.IPNet
+}
+
+func NewResolver(trustedProxies []string) *Resolver {
+	resolver := &Resolver{}
+
+	for _, proxy := range trustedProxies {
+		network, err := parseNetwork(proxy)
+		if err != nil {
+			logger.LogError(err)
+			continue
+		}
+		resolver.trustedProxies = append(resolver.trustedProxies, network)
+	}
+
+	return resolver
+}
+
+// ClientIP returns the address of the client that made the request. X-Real-IP and
+// X-Forwarded-For are only taken into account when the request came through a trusted
+// proxy, and the forwarded chain is walked from the right so that a client cannot spoof
+// its own address.
+func (resolver *Resolver) ClientIP(r *http.Request) net.IP {
+	remoteIP := RemoteIP(r)
+	if remoteIP == nil || !resolver.isTrusted(remoteIP) {
+		return remoteIP
+	}
+
+	if realIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIP != nil {
+		return realIP
+	}
+
+	forwardedFor := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	clientIP := remoteIP
+
+	for i := len(forwardedFor) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwardedFor[i]))
+		if ip == nil {
+			break
+		}
+		clientIP = ip
+		if !resolver.isTrusted(ip) {
+			break
+		}
+	}
+
+	return clientIP
+}
+
+func (resolver *Resolver) isTrusted(ip net.IP) bool {
+	for _, network := range resolver.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func RemoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+func parseNetwork(value string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+
+	if strings.Contains(value, "/") {
+		_, network, err := net.ParseCIDR(value)
+		return network, err
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid trusted proxy %q", value)
+	}
+
+	bits := 8 * net.IPv6len
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 8 * net.IPv4len
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
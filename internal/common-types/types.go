@@ -1,13 +1,203 @@
 package commontypes
 
+import (
+	"net"
+	"net/url"
+	"time"
+)
+
 type BatchRecord struct {
 	ID          string
 	ShortURLKey string
 	ShortURL    string
 	FullURL     string
+	UserID      string
+	// Err tells why the link was not made, customerrors.ErrUniqueKeyConstrantViolation
+	// when it exists already.
+	Err error
 }
 
 type RecordToBatch struct {
 	ID      string
 	FullURL string
 }
+
+// LinkOptions are the per link settings given on creation. Zero values mean
+// the deployment defaults.
+type LinkOptions struct {
+	RedirectType int
+	// PassQuery merges the query of the visit into the destination URL,
+	// QueryPrecedence decides whose parameters win: "link" or "request".
+	PassQuery       bool
+	QueryPrecedence string
+	// PassPath appends the path following the short URL id to the destination URL.
+	PassPath bool
+	// UTM parameters added to the destination URL on redirect.
+	UTM map[string]string
+	// DeviceRules are checked in order, the first matching rule replaces the destination.
+	DeviceRules []DeviceRule
+	// GeoRules are checked in order after the device rules, the first rule listing
+	// the country of the visitor replaces the destination.
+	GeoRules []GeoRule
+	// MaxClicks is the number of redirects after which the link stops working, 0 for no limit.
+	MaxClicks int64
+	// NotBefore and NotAfter limit when the link redirects, zero values for no limit.
+	NotBefore time.Time
+	NotAfter  time.Time
+	Title     string
+	Notes     string
+	Tags      []string
+	// Password is only given on creation, records keep its hash in PasswordHash.
+	Password string
+	// Alias is the short URL id chosen on creation instead of a generated one.
+	Alias string
+}
+
+// DeviceRule matches visitors by the parsed User-Agent, empty fields match anything.
+type DeviceRule struct {
+	OS     string `json:"os,omitempty"`
+	Device string `json:"device,omitempty"`
+	Bot    *bool  `json:"bot,omitempty"`
+	URL    string `json:"url"`
+}
+
+// GeoRule matches visitors by the ISO 3166-1 alpha-2 code of their country.
+type GeoRule struct {
+	Countries []string `json:"countries"`
+	URL       string   `json:"url"`
+}
+
+type URLRecord struct {
+	ShortURLKey   string
+	FullURL       string
+	UserID        string // owner, empty for links created anonymously
+	CreatedAt     time.Time
+	Clicks        int64
+	Variants      []Variant
+	VariantClicks map[string]int64
+	CountryClicks map[string]int64
+	PasswordHash  string
+	ClicksLeft    int64
+	LinkOptions
+}
+
+// Sort orders of link listings, "-" prefixed ones are descending.
+const (
+	SortCreatedAsc  = "created_at"
+	SortCreatedDesc = "-created_at"
+	SortClicksAsc   = "clicks"
+	SortClicksDesc  = "-clicks"
+	SortTitleAsc    = "title"
+	SortTitleDesc   = "-title"
+)
+
+// ListQuery selects a page of the links of a user. Tag and Search filter the links,
+// Search is a case insensitive substring of the destination or the title.
+type ListQuery struct {
+	Tag    string
+	Search string
+	Sort   string
+	After  *ListCursor
+	Limit  int
+}
+
+// ListCursor is the position of the last link of a page, the next page starts after it.
+type ListCursor struct {
+	ShortURLKey string    `json:"k"`
+	CreatedAt   time.Time `json:"c,omitempty"`
+	Clicks      int64     `json:"n,omitempty"`
+	Title       string    `json:"t,omitempty"`
+}
+
+func (record URLRecord) ListCursor() ListCursor {
+	return ListCursor{
+		ShortURLKey: record.ShortURLKey,
+		CreatedAt:   record.CreatedAt,
+		Clicks:      record.Clicks,
+		Title:       record.Title,
+	}
+}
+
+type URLPage struct {
+	Records []URLRecord
+	Next    *ListCursor
+}
+
+// HistoryEntry records a change of the destination of a link.
+type HistoryEntry struct {
+	OldURL    string    `json:"old_url"`
+	NewURL    string    `json:"new_url"`
+	ChangedAt time.Time `json:"changed_at"`
+	Editor    string    `json:"editor"`
+}
+
+// Variant is one of the destinations of an A/B split link, picked with the
+// probability proportional to its weight.
+type Variant struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// APIKey authenticates a server-to-server client of a user. Only the hash of the
+// key is stored.
+type APIKey struct {
+	ID        string
+	UserID    string
+	Name      string
+	Hash      string
+	Scopes    []string
+	CreatedAt time.Time
+	RevokedAt time.Time
+}
+
+// Quota is a limit with its current usage, a zero Limit means there is none. Daily
+// usage is only counted under a limit.
+type Quota struct {
+	Limit int64
+	Used  int64
+}
+
+// QuotaReport describes the quotas of a user or of an API key, daily quotas renew at
+// ResetsAt.
+type QuotaReport struct {
+	LinksPerDay Quota
+	ActiveLinks Quota
+	BatchSize   int64
+	ResetsAt    time.Time
+	APIKey      *QuotaReport
+}
+
+// Stats are the totals of the whole service, users are counted by the links they own.
+type Stats struct {
+	URLs  int64
+	Users int64
+}
+
+type Click struct {
+	Variant string
+	Country string
+}
+
+type Redirect struct {
+	URL        string
+	StatusCode int
+	Variant    string
+	// Private is set when the destination depends on the location of the visitor,
+	// shared caches must not keep such redirects.
+	Private bool
+	// NoStore is set for password protected and click limited links, they must not be
+	// cached at all.
+	NoStore bool
+}
+
+// Visit describes the request following a short link.
+type Visit struct {
+	Query     url.Values
+	Path      string
+	UserAgent string
+	ClientIP  net.IP
+	Password  string
+	// Variant previously assigned to the visitor.
+	Variant string
+}
@@ -33,3 +33,13 @@ func (compressorWriter CompressorWriter) Write(data []byte) (int, error) {
 func (compressorWriter CompressorWriter) Close() error {
 	return compressorWriter.gzipWriter.Close()
 }
+
+// Flush sends the data compressed so far to the client.
+func (compressorWriter *CompressorWriter) Flush() {
+	compressorWriter.gzipWriter.Flush()
+	http.NewResponseController(compressorWriter.httpWriter).Flush()
+}
+
+func (compressorWriter *CompressorWriter) Unwrap() http.ResponseWriter {
+	return compressorWriter.httpWriter
+}
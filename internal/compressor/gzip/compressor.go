@@ -1,12 +1,22 @@
 package compressor
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/with0p/golang-url-shortener.git/internal/logger"
 )
 
+type maxDecompressedSizeKey struct{}
+
+// WithMaxDecompressedSize limits how large a gzipped request body may grow once
+// decompressed, reading past it fails with *http.MaxBytesError.
+func WithMaxDecompressedSize(ctx context.Context, size int64) context.Context {
+	return context.WithValue(ctx, maxDecompressedSizeKey{}, size)
+}
+
 func HandleWithGzipCompressor(handler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		writer := w
@@ -24,12 +34,22 @@ func HandleWithGzipCompressor(handler http.HandlerFunc) http.HandlerFunc {
 			compressorReader, err := newCompressorReader(r.Body)
 
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				statusCode := http.StatusBadRequest
+				var maxBytesError *http.MaxBytesError
+				if errors.As(err, &maxBytesError) {
+					statusCode = http.StatusRequestEntityTooLarge
+				}
+				http.Error(writer, err.Error(), statusCode)
 				logger.LogError(err)
+				return
 			}
 
 			r.Body = compressorReader
 			defer compressorReader.Close()
+
+			if size, ok := r.Context().Value(maxDecompressedSizeKey{}).(int64); ok {
+				r.Body = http.MaxBytesReader(w, compressorReader, size)
+			}
 		}
 
 		handler.ServeHTTP(writer, r)
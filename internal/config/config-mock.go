@@ -5,4 +5,6 @@ var MockConfiguration = &Config{
 	ShortURL:        "http://localhost:8080",
 	FileStoragePath: "internal/storage/local-file/local-storage.json",
 	DataBaseAddress: "host=localhost port=5435 user=postgres password=1234 dbname=postgres sslmode=disable",
+
+	TrustedSubnet: "127.0.0.0/8",
 }
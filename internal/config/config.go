@@ -4,6 +4,9 @@ import (
 	"flag"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/with0p/golang-url-shortener.git/internal/logger"
 )
@@ -20,6 +23,66 @@ type Config struct {
 	ShortURL        string
 	FileStoragePath string
 	DataBaseAddress string
+
+	// Requests per minute allowed for a single client, 0 disables the limit.
+	RateLimitCreate        int
+	RateLimitCreateBurst   int
+	RateLimitRedirect      int
+	RateLimitRedirectBurst int
+	TrustedProxies         []string
+
+	AllowedSchemes      []string
+	MaxURLLength        int
+	AllowPrivateHosts   bool
+	DomainBlocklistPath string
+	DomainAllowlistPath string
+
+	// CanonicalizeURLs is opt-in: it rewrites the destinations, which not every site
+	// treats the same, and changes the ids of URLs shortened before it was enabled.
+	CanonicalizeURLs    bool
+	StripTrackingParams bool
+	TrackingParams      []string
+
+	DefaultRedirectType int
+	QueryPrecedence     string
+
+	GeoIPDatabasePath string
+
+	// Failed password attempts per minute allowed for a single link.
+	PasswordAttempts int
+
+	// ComingSoonURL is where links lead before their activation, empty for 404.
+	ComingSoonURL string
+
+	// AuthSecret signs the user cookies, when empty the storage keeps a random one.
+	AuthSecret string
+
+	// TrustedSubnet is the CIDR allowed to call the internal API, empty denies everyone.
+	TrustedSubnet string
+
+	// JWTAlgorithm switches users to JWTs, HS256 or RS256, empty keeps signed cookies.
+	JWTAlgorithm      string
+	JWTSecretFile     string
+	JWTPrivateKeyFile string
+	JWTPublicKeyFile  string
+	JWTIssuer         string
+	JWTAudience       string
+	JWTTTL            time.Duration
+
+	// Quotas of every user and of every API key, 0 disables a quota.
+	QuotaLinksPerDay    int
+	QuotaActiveLinks    int
+	QuotaBatchSize      int
+	QuotaKeyLinksPerDay int
+	QuotaKeyBatchSize   int
+	// Request body limits in bytes and batch items, 0 disables a limit.
+	MaxBodySize         int
+	MaxDecompressedSize int
+	MaxBatchItems       int
+	// Stream limits in bytes, both as sent and decompressed, and in lines, 0 disables
+	// a limit.
+	MaxStreamSize  int
+	MaxStreamLines int
 }
 
 var configuration *Config
@@ -27,11 +90,51 @@ var configuration *Config
 func GetConfig() *Config {
 	if configuration == nil {
 		var conf = Config{}
+		var trustedProxies string
+		var allowedSchemes string
+		var trackingParams string
 
 		flag.StringVar(&conf.BaseURL, "a", defaultHost+":"+defaultPort, "base URL")
 		flag.StringVar(&conf.ShortURL, "b", "http://"+defaultHost+":"+defaultPort, "short URL")
 		flag.StringVar(&conf.FileStoragePath, "f", defaultFileStoragePath, "storage path")
 		flag.StringVar(&conf.DataBaseAddress, "d", defaultDataBaseAddress, "database address")
+		flag.IntVar(&conf.RateLimitCreate, "rate-limit-create", 0, "create requests per minute per client")
+		flag.IntVar(&conf.RateLimitCreateBurst, "rate-limit-create-burst", 0, "create requests burst per client")
+		flag.IntVar(&conf.RateLimitRedirect, "rate-limit-redirect", 0, "redirect requests per minute per client")
+		flag.IntVar(&conf.RateLimitRedirectBurst, "rate-limit-redirect-burst", 0, "redirect requests burst per client")
+		flag.StringVar(&trustedProxies, "trusted-proxies", "", "comma separated trusted proxy IPs or CIDRs")
+		flag.StringVar(&allowedSchemes, "allowed-schemes", "http,https", "comma separated URL schemes allowed to shorten")
+		flag.IntVar(&conf.MaxURLLength, "max-url-length", 2048, "max length of URL to shorten, 0 for no limit")
+		flag.BoolVar(&conf.AllowPrivateHosts, "allow-private-hosts", false, "allow URLs pointing to private and loopback hosts")
+		flag.StringVar(&conf.DomainBlocklistPath, "domain-blocklist", "", "path to the file with blocked domains")
+		flag.StringVar(&conf.DomainAllowlistPath, "domain-allowlist", "", "path to the file with allowed domains")
+		flag.BoolVar(&conf.CanonicalizeURLs, "canonicalize-urls", false, "canonicalize URLs before shortening, e.g. lower-casing the host and sorting the query")
+		flag.BoolVar(&conf.StripTrackingParams, "strip-tracking-params", false, "remove tracking query parameters while canonicalizing")
+		flag.StringVar(&trackingParams, "tracking-params", "", "comma separated tracking query parameters, \"utm_*\" style prefixes allowed")
+		flag.IntVar(&conf.DefaultRedirectType, "redirect-type", 307, "default redirect status code: 301, 302, 307 or 308")
+		flag.StringVar(&conf.QueryPrecedence, "query-precedence", "link", "whose query parameters win on passthrough: link or request")
+		flag.StringVar(&conf.GeoIPDatabasePath, "geoip-db", "", "path to the MaxMind .mmdb country database for geo routing")
+		flag.IntVar(&conf.PasswordAttempts, "password-attempts", 5, "failed password attempts per minute allowed for a protected link")
+		flag.StringVar(&conf.ComingSoonURL, "coming-soon-url", "", "where links lead before their activation, 404 if empty")
+		flag.StringVar(&conf.AuthSecret, "auth-secret", "", "secret signing the user cookies")
+		flag.StringVar(&conf.TrustedSubnet, "t", "", "CIDR of the clients allowed to call the internal API")
+		flag.StringVar(&conf.JWTAlgorithm, "jwt-alg", "", "JWT algorithm identifying users, HS256 or RS256, signed cookies if empty")
+		flag.StringVar(&conf.JWTSecretFile, "jwt-secret-file", "", "path to the HS256 JWT secret")
+		flag.StringVar(&conf.JWTPrivateKeyFile, "jwt-private-key-file", "", "path to the PEM RS256 key issuing JWTs")
+		flag.StringVar(&conf.JWTPublicKeyFile, "jwt-public-key-file", "", "path to the PEM RS256 key verifying JWTs")
+		flag.StringVar(&conf.JWTIssuer, "jwt-issuer", "", "issuer of the JWTs, checked if set")
+		flag.StringVar(&conf.JWTAudience, "jwt-audience", "", "audience of the JWTs, checked if set")
+		flag.DurationVar(&conf.JWTTTL, "jwt-ttl", 365*24*time.Hour, "lifetime of the issued JWTs")
+		flag.IntVar(&conf.QuotaLinksPerDay, "quota-links-per-day", 0, "links a user may create per day, 0 for no limit")
+		flag.IntVar(&conf.QuotaActiveLinks, "quota-active-links", 0, "active links a user may have, 0 for no limit")
+		flag.IntVar(&conf.QuotaBatchSize, "quota-batch-size", 0, "links a user may create in one batch, 0 for no limit")
+		flag.IntVar(&conf.QuotaKeyLinksPerDay, "quota-key-links-per-day", 0, "links an API key may create per day, 0 for no limit")
+		flag.IntVar(&conf.QuotaKeyBatchSize, "quota-key-batch-size", 0, "links an API key may create in one batch, 0 for no limit")
+		flag.IntVar(&conf.MaxBodySize, "max-body-size", 1<<20, "max request body size in bytes, 0 for no limit")
+		flag.IntVar(&conf.MaxDecompressedSize, "max-decompressed-size", 10<<20, "max size of a gzipped request body once decompressed, 0 for no limit")
+		flag.IntVar(&conf.MaxBatchItems, "max-batch-items", 1000, "max records in one batch request, 0 for no limit")
+		flag.IntVar(&conf.MaxStreamSize, "max-stream-size", 100<<20, "max size of a streamed request body in bytes, as sent and decompressed, 0 for no limit")
+		flag.IntVar(&conf.MaxStreamLines, "max-stream-lines", 100000, "max lines in one streamed request, 0 for no limit")
 		flag.Parse()
 
 		if envServerAddress := os.Getenv("SERVER_ADDRESS"); envServerAddress != "" {
@@ -50,12 +153,105 @@ func GetConfig() *Config {
 			conf.DataBaseAddress = envDatabaseStoragePath
 		}
 
-		configuration = &Config{
-			BaseURL:         URLParseHelper(conf.BaseURL),
-			ShortURL:        "http://" + URLParseHelper(conf.ShortURL),
-			FileStoragePath: conf.FileStoragePath,
-			DataBaseAddress: conf.DataBaseAddress,
+		intFromEnv("RATE_LIMIT_CREATE", &conf.RateLimitCreate)
+		intFromEnv("RATE_LIMIT_CREATE_BURST", &conf.RateLimitCreateBurst)
+		intFromEnv("RATE_LIMIT_REDIRECT", &conf.RateLimitRedirect)
+		intFromEnv("RATE_LIMIT_REDIRECT_BURST", &conf.RateLimitRedirectBurst)
+
+		if envTrustedProxies := os.Getenv("TRUSTED_PROXIES"); envTrustedProxies != "" {
+			trustedProxies = envTrustedProxies
+		}
+
+		if envAllowedSchemes := os.Getenv("ALLOWED_SCHEMES"); envAllowedSchemes != "" {
+			allowedSchemes = envAllowedSchemes
+		}
+
+		intFromEnv("MAX_URL_LENGTH", &conf.MaxURLLength)
+		boolFromEnv("ALLOW_PRIVATE_HOSTS", &conf.AllowPrivateHosts)
+
+		if envDomainBlocklistPath := os.Getenv("DOMAIN_BLOCKLIST_PATH"); envDomainBlocklistPath != "" {
+			conf.DomainBlocklistPath = envDomainBlocklistPath
+		}
+
+		if envDomainAllowlistPath := os.Getenv("DOMAIN_ALLOWLIST_PATH"); envDomainAllowlistPath != "" {
+			conf.DomainAllowlistPath = envDomainAllowlistPath
+		}
+
+		boolFromEnv("CANONICALIZE_URLS", &conf.CanonicalizeURLs)
+		boolFromEnv("STRIP_TRACKING_PARAMS", &conf.StripTrackingParams)
+
+		if envTrackingParams := os.Getenv("TRACKING_PARAMS"); envTrackingParams != "" {
+			trackingParams = envTrackingParams
+		}
+
+		intFromEnv("REDIRECT_TYPE", &conf.DefaultRedirectType)
+
+		if envQueryPrecedence := os.Getenv("QUERY_PRECEDENCE"); envQueryPrecedence != "" {
+			conf.QueryPrecedence = envQueryPrecedence
+		}
+
+		if envGeoIPDatabasePath := os.Getenv("GEOIP_DB_PATH"); envGeoIPDatabasePath != "" {
+			conf.GeoIPDatabasePath = envGeoIPDatabasePath
+		}
+
+		intFromEnv("PASSWORD_ATTEMPTS", &conf.PasswordAttempts)
+
+		if envComingSoonURL := os.Getenv("COMING_SOON_URL"); envComingSoonURL != "" {
+			conf.ComingSoonURL = envComingSoonURL
+		}
+
+		if envAuthSecret := os.Getenv("AUTH_SECRET"); envAuthSecret != "" {
+			conf.AuthSecret = envAuthSecret
+		}
+
+		if envTrustedSubnet := os.Getenv("TRUSTED_SUBNET"); envTrustedSubnet != "" {
+			conf.TrustedSubnet = envTrustedSubnet
+		}
+
+		if envJWTAlgorithm := os.Getenv("JWT_ALGORITHM"); envJWTAlgorithm != "" {
+			conf.JWTAlgorithm = envJWTAlgorithm
+		}
+
+		if envJWTSecretFile := os.Getenv("JWT_SECRET_FILE"); envJWTSecretFile != "" {
+			conf.JWTSecretFile = envJWTSecretFile
 		}
+
+		if envJWTPrivateKeyFile := os.Getenv("JWT_PRIVATE_KEY_FILE"); envJWTPrivateKeyFile != "" {
+			conf.JWTPrivateKeyFile = envJWTPrivateKeyFile
+		}
+
+		if envJWTPublicKeyFile := os.Getenv("JWT_PUBLIC_KEY_FILE"); envJWTPublicKeyFile != "" {
+			conf.JWTPublicKeyFile = envJWTPublicKeyFile
+		}
+
+		if envJWTIssuer := os.Getenv("JWT_ISSUER"); envJWTIssuer != "" {
+			conf.JWTIssuer = envJWTIssuer
+		}
+
+		if envJWTAudience := os.Getenv("JWT_AUDIENCE"); envJWTAudience != "" {
+			conf.JWTAudience = envJWTAudience
+		}
+
+		durationFromEnv("JWT_TTL", &conf.JWTTTL)
+
+		intFromEnv("QUOTA_LINKS_PER_DAY", &conf.QuotaLinksPerDay)
+		intFromEnv("QUOTA_ACTIVE_LINKS", &conf.QuotaActiveLinks)
+		intFromEnv("QUOTA_BATCH_SIZE", &conf.QuotaBatchSize)
+		intFromEnv("QUOTA_KEY_LINKS_PER_DAY", &conf.QuotaKeyLinksPerDay)
+		intFromEnv("QUOTA_KEY_BATCH_SIZE", &conf.QuotaKeyBatchSize)
+		intFromEnv("MAX_BODY_SIZE", &conf.MaxBodySize)
+		intFromEnv("MAX_DECOMPRESSED_SIZE", &conf.MaxDecompressedSize)
+		intFromEnv("MAX_BATCH_ITEMS", &conf.MaxBatchItems)
+		intFromEnv("MAX_STREAM_SIZE", &conf.MaxStreamSize)
+		intFromEnv("MAX_STREAM_LINES", &conf.MaxStreamLines)
+
+		conf.BaseURL = URLParseHelper(conf.BaseURL)
+		conf.ShortURL = "http://" + URLParseHelper(conf.ShortURL)
+		conf.TrustedProxies = splitList(trustedProxies)
+		conf.AllowedSchemes = splitList(allowedSchemes)
+		conf.TrackingParams = splitList(trackingParams)
+
+		configuration = &conf
 	}
 
 	return configuration
@@ -87,3 +283,55 @@ func URLParseHelper(str string) string {
 
 	return host + ":" + port
 }
+
+func intFromEnv(name string, target *int) {
+	envValue := os.Getenv(name)
+	if envValue == "" {
+		return
+	}
+
+	value, err := strconv.Atoi(envValue)
+	if err != nil {
+		logger.LogError(err)
+		return
+	}
+	*target = value
+}
+
+func boolFromEnv(name string, target *bool) {
+	envValue := os.Getenv(name)
+	if envValue == "" {
+		return
+	}
+
+	value, err := strconv.ParseBool(envValue)
+	if err != nil {
+		logger.LogError(err)
+		return
+	}
+	*target = value
+}
+
+func durationFromEnv(name string, target *time.Duration) {
+	envValue := os.Getenv(name)
+	if envValue == "" {
+		return
+	}
+
+	value, err := time.ParseDuration(envValue)
+	if err != nil {
+		logger.LogError(err)
+		return
+	}
+	*target = value
+}
+
+func splitList(str string) []string {
+	var list []string
+	for _, item := range strings.Split(str, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -1,5 +1,51 @@
 package customerrors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 var ErrUniqueKeyConstrantViolation = errors.New("unique key violation")
+var ErrURLNotAllowed = errors.New("URL is not allowed")
+var ErrNotFound = errors.New("not found")
+var ErrUnauthorized = errors.New("unauthorized")
+var ErrForbidden = errors.New("forbidden")
+var ErrPasswordRequired = errors.New("password required")
+var ErrWrongPassword = errors.New("wrong password")
+var ErrTooManyAttempts = errors.New("too many attempts")
+var ErrLinkExhausted = errors.New("link has no clicks left")
+var ErrLinkNotActive = errors.New("link is not active yet")
+var ErrLinkExpired = errors.New("link has expired")
+var ErrQuotaExceeded = errors.New("quota exceeded")
+var ErrAliasTaken = errors.New("alias is taken")
+
+// RetryAfterError tells when the failed operation may be tried again.
+type RetryAfterError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// QuotaError names the quota that has been hit, RetryAfter is set for the quotas
+// renewed over time.
+type QuotaError struct {
+	Quota      string
+	Limit      int64
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: %s limit of %d reached", ErrQuotaExceeded, e.Quota, e.Limit)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
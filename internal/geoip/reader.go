@@ -0,0 +1,72 @@
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+var ErrInvalidDatabase = errors.New("invalid MaxMind database")
+
+// Reader looks up countries in a MaxMind DB file (GeoLite2/GeoIP2 Country or City).
+type Reader struct {
+	db *maxminddb.Reader
+}
+
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+func Open(path string) (*Reader, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &Reader{db: db}, nil
+}
+
+func NewReader(buffer []byte) (*Reader, error) {
+	db, err := maxminddb.FromBytes(buffer)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &Reader{db: db}, nil
+}
+
+func (reader *Reader) DatabaseType() string {
+	return reader.db.Metadata.DatabaseType
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of the country the IP is located in,
+// falling back to the country it is registered in. It is empty when unknown.
+func (reader *Reader) Country(ip net.IP) (string, error) {
+	var record countryRecord
+	if err := reader.db.Lookup(ip, &record); err != nil {
+		return "", wrapError(err)
+	}
+
+	if record.Country.ISOCode != "" {
+		return strings.ToUpper(record.Country.ISOCode), nil
+	}
+	return strings.ToUpper(record.RegisteredCountry.ISOCode), nil
+}
+
+func (reader *Reader) Close() error {
+	return reader.db.Close()
+}
+
+func wrapError(err error) error {
+	var invalid maxminddb.InvalidDatabaseError
+	if errors.As(err, &invalid) {
+		return fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+	}
+	return err
+}
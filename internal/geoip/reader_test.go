@@ -0,0 +1,86 @@
+package geoip
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildDatabase(t *testing.T, networks map[string]mmdbtype.Map) []byte {
+	t.Helper()
+
+	tree, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType:            "Test-Country",
+		RecordSize:              24,
+		IncludeReservedNetworks: true,
+	})
+	require.NoError(t, err)
+
+	for cidr, record := range networks {
+		_, network, err := net.ParseCIDR(cidr)
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(network, record))
+	}
+
+	var database bytes.Buffer
+	_, err = tree.WriteTo(&database)
+	require.NoError(t, err)
+
+	return database.Bytes()
+}
+
+func TestCountry(t *testing.T) {
+	database := buildDatabase(t, map[string]mmdbtype.Map{
+		"81.2.69.0/24": {
+			"country": mmdbtype.Map{"iso_code": mmdbtype.String("GB")},
+		},
+		"89.160.0.0/16": {
+			"registered_country": mmdbtype.Map{"iso_code": mmdbtype.String("se")},
+		},
+		"2001:db8::/32": {
+			"country":            mmdbtype.Map{"iso_code": mmdbtype.String("DE")},
+			"registered_country": mmdbtype.Map{"iso_code": mmdbtype.String("FR")},
+		},
+	})
+
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	require.NoError(t, os.WriteFile(path, database, 0o600))
+
+	reader, err := Open(path)
+	require.NoError(t, err)
+	defer reader.Close()
+	assert.Equal(t, "Test-Country", reader.DatabaseType())
+
+	tests := []struct {
+		ip      string
+		country string
+	}{
+		{ip: "81.2.69.142", country: "GB"},
+		{ip: "::ffff:81.2.69.1", country: "GB"},
+		{ip: "89.160.20.112", country: "SE"},
+		{ip: "2001:db8::1", country: "DE"},
+		{ip: "81.2.70.1", country: ""},
+		{ip: "10.0.0.1", country: ""},
+		{ip: "2001:db9::1", country: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			country, err := reader.Country(net.ParseIP(test.ip))
+			require.NoError(t, err)
+			assert.Equal(t, test.country, country)
+		})
+	}
+}
+
+func TestNewReaderInvalid(t *testing.T) {
+	_, err := NewReader([]byte("not a database"))
+	assert.ErrorIs(t, err, ErrInvalidDatabase)
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+)
+
+type InternalStatsResponse struct {
+	URLs  int64 `json:"urls"`
+	Users int64 `json:"users"`
+}
+
+func (handler *URLHandler) GetInternalStats(res http.ResponseWriter, req *http.Request) {
+	stats, serviceErr := handler.service.GetStats(req.Context())
+	if serviceErr != nil {
+		http.Error(res, serviceErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, InternalStatsResponse{
+		URLs:  stats.URLs,
+		Users: stats.Users,
+	})
+}
+
+func (handler *URLHandler) ForceDeleteURL(res http.ResponseWriter, req *http.Request) {
+	serviceErr := handler.service.ForceDeleteURL(req.Context(), chi.URLParam(req, "id"))
+	if serviceErr != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(serviceErr, customerrors.ErrNotFound) {
+			statusCode = http.StatusNotFound
+		}
+		http.Error(res, serviceErr.Error(), statusCode)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
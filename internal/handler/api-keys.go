@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/with0p/golang-url-shortener.git/internal/auth"
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+)
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type APIKeyResponse struct {
+	ID        string     `json:"id"`
+	Key       string     `json:"key,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (handler *URLHandler) CreateAPIKey(res http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("content-type") != "application/json" {
+		http.Error(res, "Not a \"application/json\" content-type", http.StatusBadRequest)
+		return
+	}
+
+	defer req.Body.Close()
+	body, bodyReadError := io.ReadAll(req.Body)
+	if bodyReadError != nil {
+		writeBodyError(res, bodyReadError)
+		logger.LogError(bodyReadError)
+		return
+	}
+
+	var requestPayload CreateAPIKeyRequest
+	if err := json.Unmarshal(body, &requestPayload); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		logger.LogError(err)
+		return
+	}
+
+	apiKey, key, serviceErr := handler.service.CreateAPIKey(req.Context(), requestPayload.Name, requestPayload.Scopes)
+	if serviceErr != nil {
+		http.Error(res, serviceErr.Error(), ownerErrorStatus(serviceErr))
+		return
+	}
+
+	writeJSON(res, http.StatusCreated, APIKeyResponse{
+		ID:        apiKey.ID,
+		Key:       key,
+		Name:      apiKey.Name,
+		Scopes:    apiKey.Scopes,
+		CreatedAt: apiKey.CreatedAt,
+	})
+}
+
+func (handler *URLHandler) ListAPIKeys(res http.ResponseWriter, req *http.Request) {
+	apiKeys, serviceErr := handler.service.ListAPIKeys(req.Context())
+	if serviceErr != nil {
+		http.Error(res, serviceErr.Error(), ownerErrorStatus(serviceErr))
+		return
+	}
+
+	responsePayload := make([]APIKeyResponse, len(apiKeys))
+	for i, apiKey := range apiKeys {
+		responsePayload[i] = APIKeyResponse{
+			ID:        apiKey.ID,
+			Name:      apiKey.Name,
+			Scopes:    apiKey.Scopes,
+			CreatedAt: apiKey.CreatedAt,
+		}
+		if !apiKey.RevokedAt.IsZero() {
+			responsePayload[i].RevokedAt = &apiKey.RevokedAt
+		}
+	}
+
+	writeJSON(res, http.StatusOK, responsePayload)
+}
+
+func (handler *URLHandler) RevokeAPIKey(res http.ResponseWriter, req *http.Request) {
+	if serviceErr := handler.service.RevokeAPIKey(req.Context(), chi.URLParam(req, "id")); serviceErr != nil {
+		http.Error(res, serviceErr.Error(), ownerErrorStatus(serviceErr))
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (handler *URLHandler) resolveAPIKey(ctx context.Context, key string) (auth.Identity, error) {
+	apiKey, err := handler.service.AuthenticateAPIKey(ctx, key)
+	if err != nil {
+		return auth.Identity{}, err
+	}
+
+	return auth.Identity{UserID: apiKey.UserID, APIKeyID: apiKey.ID, Scopes: apiKey.Scopes}, nil
+}
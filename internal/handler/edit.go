@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+)
+
+type UpdateURLRequest struct {
+	URL string `json:"url"`
+}
+
+type UpdateURLResponse struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+}
+
+type HistoryResponseRecord struct {
+	OldURL    string    `json:"old_url"`
+	NewURL    string    `json:"new_url"`
+	ChangedAt time.Time `json:"changed_at"`
+	Editor    string    `json:"editor"`
+}
+
+func (handler *URLHandler) UpdateURL(res http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("content-type") != "application/json" {
+		http.Error(res, "Not a \"application/json\" content-type", http.StatusBadRequest)
+		return
+	}
+
+	defer req.Body.Close()
+	body, bodyReadError := io.ReadAll(req.Body)
+	if bodyReadError != nil {
+		writeBodyError(res, bodyReadError)
+		logger.LogError(bodyReadError)
+		return
+	}
+
+	var requestPayload UpdateURLRequest
+	if err := json.Unmarshal(body, &requestPayload); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		logger.LogError(err)
+		return
+	}
+
+	record, serviceErr := handler.service.UpdateURL(req.Context(), chi.URLParam(req, "id"), requestPayload.URL)
+	if serviceErr != nil {
+		http.Error(res, serviceErr.Error(), ownerErrorStatus(serviceErr))
+		return
+	}
+
+	writeJSON(res, http.StatusOK, UpdateURLResponse{
+		ShortURL:    handler.shortURLHost + "/" + record.ShortURLKey,
+		OriginalURL: record.FullURL,
+	})
+}
+
+func (handler *URLHandler) DeleteURL(res http.ResponseWriter, req *http.Request) {
+	if serviceErr := handler.service.DeleteURL(req.Context(), chi.URLParam(req, "id")); serviceErr != nil {
+		http.Error(res, serviceErr.Error(), ownerErrorStatus(serviceErr))
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (handler *URLHandler) GetURLHistory(res http.ResponseWriter, req *http.Request) {
+	history, serviceErr := handler.service.GetURLHistory(req.Context(), chi.URLParam(req, "id"))
+	if serviceErr != nil {
+		http.Error(res, serviceErr.Error(), ownerErrorStatus(serviceErr))
+		return
+	}
+
+	responsePayload := make([]HistoryResponseRecord, len(history))
+	for i, entry := range history {
+		responsePayload[i] = HistoryResponseRecord{
+			OldURL:    entry.OldURL,
+			NewURL:    entry.NewURL,
+			ChangedAt: entry.ChangedAt,
+			Editor:    entry.Editor,
+		}
+	}
+
+	writeJSON(res, http.StatusOK, responsePayload)
+}
+
+// ownerErrorStatus maps the errors of the operations allowed to the link owner only.
+func ownerErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, customerrors.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, customerrors.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, customerrors.ErrNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func writeJSON(res http.ResponseWriter, statusCode int, payload any) {
+	response, err := json.Marshal(payload)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		logger.LogError(err)
+		return
+	}
+
+	res.Header().Set("content-type", "application/json")
+	res.WriteHeader(statusCode)
+	res.Write(response)
+}
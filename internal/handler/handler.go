@@ -3,30 +3,145 @@ package handler
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/with0p/golang-url-shortener.git/internal/auth"
+	"github.com/with0p/golang-url-shortener.git/internal/bodylimit"
+	"github.com/with0p/golang-url-shortener.git/internal/clientip"
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	"github.com/with0p/golang-url-shortener.git/internal/config"
 	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
 	"github.com/with0p/golang-url-shortener.git/internal/logger"
 	"github.com/with0p/golang-url-shortener.git/internal/middlewares"
+	"github.com/with0p/golang-url-shortener.git/internal/ratelimiter"
 	"github.com/with0p/golang-url-shortener.git/internal/service"
+	"github.com/with0p/golang-url-shortener.git/internal/trustedsubnet"
 )
 
 type URLHandler struct {
-	service service.Service
+	service             service.Service
+	shortURLHost        string
+	clientIPResolver    *clientip.Resolver
+	createMiddlewares   []middlewares.Middleware
+	redirectMiddlewares []middlewares.Middleware
+	apiMiddlewares      []middlewares.Middleware
+	deleteMiddlewares   []middlewares.Middleware
+	keyMiddlewares      []middlewares.Middleware
+	adminMiddlewares    []middlewares.Middleware
+	streamMiddlewares   []middlewares.Middleware
+	maxBatchItems       int
+	maxStreamLines      int
 }
 
-func NewURLHandler(currentService service.Service) *URLHandler {
-	return &URLHandler{service: currentService}
+func NewURLHandler(currentService service.Service, config *config.Config) (*URLHandler, error) {
+	handler := &URLHandler{
+		service:          currentService,
+		shortURLHost:     config.ShortURL,
+		clientIPResolver: clientip.NewResolver(config.TrustedProxies),
+		maxBatchItems:    config.MaxBatchItems,
+		maxStreamLines:   config.MaxStreamLines,
+		// API keys are checked for their scope once authenticated.
+		createMiddlewares: []middlewares.Middleware{auth.RequireScope(auth.ScopeCreate)},
+		apiMiddlewares:    []middlewares.Middleware{auth.RequireScope(auth.ScopeReadStats)},
+		deleteMiddlewares: []middlewares.Middleware{auth.RequireScope(auth.ScopeDelete)},
+	}
+
+	if config.RateLimitCreate > 0 {
+		limiter := ratelimiter.NewRateLimiter(config.RateLimitCreate, config.RateLimitCreateBurst, handler.rateLimitKey)
+		handler.createMiddlewares = append(handler.createMiddlewares, limiter.HandleWithRateLimit)
+	}
+
+	if config.RateLimitRedirect > 0 {
+		limiter := ratelimiter.NewRateLimiter(config.RateLimitRedirect, config.RateLimitRedirectBurst, handler.rateLimitKey)
+		handler.redirectMiddlewares = append(handler.redirectMiddlewares, limiter.HandleWithRateLimit)
+	}
+
+	// Extra middlewares run in reverse order, users are authenticated before rate
+	// limiting so that they are limited by their id.
+	authenticator, err := newAuthenticator(config, handler.resolveAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	handler.createMiddlewares = append(handler.createMiddlewares, authenticator.HandleWithAuth)
+	handler.apiMiddlewares = append(handler.apiMiddlewares, authenticator.HandleWithAuth)
+	handler.deleteMiddlewares = append(handler.deleteMiddlewares, authenticator.HandleWithAuth)
+	handler.keyMiddlewares = append(handler.keyMiddlewares, authenticator.HandleWithAuth)
+
+	guard := trustedsubnet.NewGuard(config.TrustedSubnet, handler.clientIPResolver)
+	handler.adminMiddlewares = append(handler.adminMiddlewares, guard.HandleWithTrustedSubnet)
+
+	// Streams are read chunk by chunk, so they have limits of their own, the same one
+	// applies once decompressed.
+	streamLimiter := bodylimit.NewLimiter(int64(config.MaxStreamSize), int64(config.MaxStreamSize))
+	handler.streamMiddlewares = append(slices.Clone(handler.createMiddlewares), streamLimiter.HandleWithBodyLimit)
+
+	// Oversized bodies are refused before anything else is done with the request.
+	bodyLimiter := bodylimit.NewLimiter(int64(config.MaxBodySize), int64(config.MaxDecompressedSize))
+	handler.createMiddlewares = append(handler.createMiddlewares, bodyLimiter.HandleWithBodyLimit)
+	handler.redirectMiddlewares = append(handler.redirectMiddlewares, bodyLimiter.HandleWithBodyLimit)
+	handler.apiMiddlewares = append(handler.apiMiddlewares, bodyLimiter.HandleWithBodyLimit)
+	handler.deleteMiddlewares = append(handler.deleteMiddlewares, bodyLimiter.HandleWithBodyLimit)
+	handler.keyMiddlewares = append(handler.keyMiddlewares, bodyLimiter.HandleWithBodyLimit)
+	handler.adminMiddlewares = append(handler.adminMiddlewares, bodyLimiter.HandleWithBodyLimit)
+
+	return handler, nil
+}
+
+// newAuthenticator signs users with JWTs when an algorithm is configured and with
+// signed cookies otherwise. A broken JWT setup is an error rather than a silent fallback,
+// since tokens issued by the other instances would not be accepted.
+func newAuthenticator(config *config.Config, apiKeys auth.APIKeyResolver) (*auth.Authenticator, error) {
+	if config.JWTAlgorithm == "" {
+		return auth.NewAuthenticator(config.AuthSecret, apiKeys), nil
+	}
+
+	jwt, err := auth.NewJWT(auth.JWTOptions{
+		Algorithm:      config.JWTAlgorithm,
+		SecretFile:     config.JWTSecretFile,
+		PrivateKeyFile: config.JWTPrivateKeyFile,
+		PublicKeyFile:  config.JWTPublicKeyFile,
+		Issuer:         config.JWTIssuer,
+		Audience:       config.JWTAudience,
+		TTL:            config.JWTTTL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot use JWTs: %w", err)
+	}
+
+	return auth.NewJWTAuthenticator(jwt, apiKeys), nil
 }
 
 func (handler *URLHandler) GetHTTPHandler(db *sql.DB) http.Handler {
 	mux := chi.NewRouter()
-	mux.Post(`/`, middlewares.UseMiddlewares(handler.DoShortURL))
-	mux.Get(`/{id}`, middlewares.UseMiddlewares(handler.DoGetTrueURL))
-	mux.Post(`/api/shorten`, middlewares.UseMiddlewares(handler.Shorten))
-	mux.Post(`/api/shorten/batch`, middlewares.UseMiddlewares(handler.ShortenBatch))
+	mux.Post(`/`, middlewares.UseMiddlewares(handler.DoShortURL, handler.createMiddlewares...))
+	mux.Get(`/{id}`, middlewares.UseMiddlewares(handler.DoGetTrueURL, handler.redirectMiddlewares...))
+	mux.Get(`/{id}/qr`, middlewares.UseMiddlewares(handler.DoQRCode, handler.redirectMiddlewares...))
+	mux.Get(`/{id}/*`, middlewares.UseMiddlewares(handler.DoGetTrueURL, handler.redirectMiddlewares...))
+	mux.Post(`/{id}/unlock`, middlewares.UseMiddlewares(handler.DoUnlock, handler.redirectMiddlewares...))
+	mux.Post(`/api/shorten`, middlewares.UseMiddlewares(handler.Shorten, handler.createMiddlewares...))
+	mux.Post(`/api/shorten/batch`, middlewares.UseMiddlewares(handler.ShortenBatch, handler.createMiddlewares...))
+	mux.Post(`/api/shorten/stream`, middlewares.UseMiddlewares(handler.ShortenStream, handler.streamMiddlewares...))
+	mux.Patch(`/api/urls/{id}`, middlewares.UseMiddlewares(handler.UpdateURL, handler.createMiddlewares...))
+	mux.Delete(`/api/urls/{id}`, middlewares.UseMiddlewares(handler.DeleteURL, handler.deleteMiddlewares...))
+	mux.Get(`/api/urls/{id}/history`, middlewares.UseMiddlewares(handler.GetURLHistory, handler.apiMiddlewares...))
+	mux.Get(`/api/urls/{id}/variants`, middlewares.UseMiddlewares(handler.GetVariants, handler.apiMiddlewares...))
+	mux.Post(`/api/urls/{id}/variants`, middlewares.UseMiddlewares(handler.SetVariants, handler.createMiddlewares...))
+	mux.Get(`/api/urls/{id}/stats`, middlewares.UseMiddlewares(handler.GetStats, handler.apiMiddlewares...))
+	mux.Post(`/api/keys`, middlewares.UseMiddlewares(handler.CreateAPIKey, handler.keyMiddlewares...))
+	mux.Get(`/api/keys`, middlewares.UseMiddlewares(handler.ListAPIKeys, handler.keyMiddlewares...))
+	mux.Delete(`/api/keys/{id}`, middlewares.UseMiddlewares(handler.RevokeAPIKey, handler.keyMiddlewares...))
+	mux.Get(`/api/admin/urls`, middlewares.UseMiddlewares(handler.ListURLs, handler.adminMiddlewares...))
+	mux.Delete(`/api/admin/urls/{id}`, middlewares.UseMiddlewares(handler.ForceDeleteURL, handler.adminMiddlewares...))
+	mux.Get(`/api/internal/stats`, middlewares.UseMiddlewares(handler.GetInternalStats, handler.adminMiddlewares...))
+	mux.Get(`/api/user/quota`, middlewares.UseMiddlewares(handler.GetQuota, handler.keyMiddlewares...))
+	mux.Get(`/api/user/urls`, middlewares.UseMiddlewares(handler.ListUserURLs, handler.apiMiddlewares...))
+	mux.Post(`/api/import`, middlewares.UseMiddlewares(handler.ImportURLs, handler.createMiddlewares...))
+	mux.Get(`/api/export`, middlewares.UseMiddlewares(handler.ExportURLs, handler.apiMiddlewares...))
 	mux.Get(`/ping`, getPingDB(db))
 
 	return mux
@@ -41,16 +156,20 @@ func (handler *URLHandler) DoShortURL(res http.ResponseWriter, req *http.Request
 	defer req.Body.Close()
 	body, bodyReadError := io.ReadAll(req.Body)
 	if bodyReadError != nil {
-		http.Error(res, bodyReadError.Error(), http.StatusBadRequest)
+		writeBodyError(res, bodyReadError)
 		logger.LogError(bodyReadError)
 		return
 	}
 
 	statusCode := http.StatusCreated
 
-	shortURL, serviceErr := handler.service.MakeShortURL(req.Context(), string(body))
+	shortURL, serviceErr := handler.service.MakeShortURL(req.Context(), string(body), commontypes.LinkOptions{})
 
 	if serviceErr != nil {
+		if writeQuotaError(res, serviceErr) {
+			return
+		}
+
 		if errors.Is(serviceErr, customerrors.ErrUniqueKeyConstrantViolation) {
 			statusCode = http.StatusConflict
 		} else {
@@ -64,6 +183,16 @@ func (handler *URLHandler) DoShortURL(res http.ResponseWriter, req *http.Request
 	res.Write([]byte(shortURL))
 }
 
+// writeBodyError answers 413 when the request body is over the limits and 400 otherwise.
+func writeBodyError(res http.ResponseWriter, err error) {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		http.Error(res, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(res, err.Error(), http.StatusBadRequest)
+}
+
 func (handler *URLHandler) DoGetTrueURL(res http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodGet {
 		http.Error(res, "Not a GET requests", http.StatusMethodNotAllowed)
@@ -72,12 +201,84 @@ func (handler *URLHandler) DoGetTrueURL(res http.ResponseWriter, req *http.Reque
 
 	id := chi.URLParam(req, "id")
 
-	trueURL, error := handler.service.GetTrueURL(req.Context(), id)
+	if isPreviewRequest(req, id) {
+		handler.DoPreview(res, req, id)
+		return
+	}
+
+	visit := commontypes.Visit{
+		Query:     req.URL.Query(),
+		Path:      chi.URLParam(req, "*"),
+		UserAgent: req.UserAgent(),
+		ClientIP:  handler.clientIPResolver.ClientIP(req),
+		Password:  basicAuthPassword(req),
+		Variant:   readVariantCookie(req, id),
+	}
+
+	redirect, error := handler.service.GetTrueURL(req.Context(), id, visit)
 	if error != nil {
-		http.Error(res, error.Error(), http.StatusNotFound)
+		handler.handleRedirectError(res, req, id, visit, error)
 		return
 	}
-	http.Redirect(res, req, trueURL, http.StatusTemporaryRedirect)
+
+	res.Header().Set("Cache-Control", redirectCacheControl(redirect))
+	res.Header().Add("Vary", "User-Agent")
+	if redirect.Variant != "" {
+		setVariantCookie(res, id, redirect.Variant)
+	}
+	http.Redirect(res, req, redirect.URL, redirect.StatusCode)
+}
+
+func (handler *URLHandler) handleRedirectError(res http.ResponseWriter, req *http.Request, id string, visit commontypes.Visit, err error) {
+	var retryAfterErr *customerrors.RetryAfterError
+
+	switch {
+	case errors.Is(err, customerrors.ErrPasswordRequired):
+		handler.writePasswordForm(res, req, id, visit, "")
+	case errors.Is(err, customerrors.ErrWrongPassword):
+		handler.writePasswordForm(res, req, id, visit, "Wrong password, try again.")
+	case errors.Is(err, customerrors.ErrLinkExhausted), errors.Is(err, customerrors.ErrLinkExpired):
+		res.Header().Set("Cache-Control", "private, no-store")
+		http.Error(res, err.Error(), http.StatusGone)
+	case errors.As(err, &retryAfterErr):
+		res.Header().Set("Retry-After", strconv.Itoa(int(retryAfterErr.RetryAfter.Seconds())+1))
+		http.Error(res, "Too many password attempts, try again later", http.StatusTooManyRequests)
+	default:
+		http.Error(res, err.Error(), http.StatusNotFound)
+	}
+}
+
+// redirectCacheControl lets clients cache permanent redirects, temporary ones must
+// reach the server every time so that the destination can change and clicks are counted.
+// Split links are never cached as the variant cookie has to be set, neither are
+// password protected and click limited ones.
+// Geo routed permanent redirects are cached by the browser only.
+func redirectCacheControl(redirect commontypes.Redirect) string {
+	if redirect.Variant != "" || redirect.NoStore {
+		return "private, no-store"
+	}
+
+	switch redirect.StatusCode {
+	case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		if redirect.Private {
+			return "private, max-age=86400"
+		}
+		return "public, max-age=86400"
+	default:
+		return "private, no-store"
+	}
+}
+
+// rateLimitKey limits users by their id, clients that have not presented one yet
+// by their IP, as they could get a new id with every request.
+func (handler *URLHandler) rateLimitKey(req *http.Request) string {
+	if identity, ok := auth.IdentityFromContext(req.Context()); ok && !identity.Issued {
+		return "user:" + identity.UserID
+	}
+	if ip := handler.clientIPResolver.ClientIP(req); ip != nil {
+		return ip.String()
+	}
+	return req.RemoteAddr
 }
 
 func getPingDB(db *sql.DB) http.HandlerFunc {
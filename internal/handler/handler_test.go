@@ -2,15 +2,29 @@ package handler
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"image/png"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/with0p/golang-url-shortener.git/internal/auth"
 	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
 	"github.com/with0p/golang-url-shortener.git/internal/config"
 	"github.com/with0p/golang-url-shortener.git/internal/mock"
@@ -18,33 +32,48 @@ import (
 	"github.com/with0p/golang-url-shortener.git/internal/storage"
 )
 
+func mustNewURLHandler(currentService service.Service, conf *config.Config) *URLHandler {
+	handler, err := NewURLHandler(currentService, conf)
+	if err != nil {
+		panic(err)
+	}
+	return handler
+}
+
 func getInMemoryMocks() *URLHandler {
-	inMemoryStorage := storage.NewInMemoryStorage(map[string]string{})
-	service := service.NewShortURLService(inMemoryStorage, config.MockConfiguration.ShortURL)
-	handler := NewURLHandler(service)
+	inMemoryStorage := storage.NewInMemoryStorage(storage.URLStorageMap{})
+	service := service.NewShortURLService(inMemoryStorage, config.MockConfiguration)
+	handler := mustNewURLHandler(service, config.MockConfiguration)
 
 	return handler
 }
 
 func getHandlerGetTrueURLMock(ctrl *gomock.Controller, key string, value string) *URLHandler {
 	mockService := mock.NewMockService(ctrl)
-	mockService.EXPECT().GetTrueURL(gomock.Any(), key).Return(value, nil)
+	mockService.EXPECT().GetTrueURL(gomock.Any(), key, gomock.Any()).Return(commontypes.Redirect{URL: value, StatusCode: http.StatusTemporaryRedirect}, nil)
+
+	return mustNewURLHandler(mockService, config.MockConfiguration)
+}
+
+func getHandlerGetURLRecordMock(ctrl *gomock.Controller, key string, value commontypes.URLRecord) *URLHandler {
+	mockService := mock.NewMockService(ctrl)
+	mockService.EXPECT().GetURLRecord(gomock.Any(), key).Return(value, nil)
 
-	return NewURLHandler(mockService)
+	return mustNewURLHandler(mockService, config.MockConfiguration)
 }
 
 func getHandlerMakeShortURLMock(ctrl *gomock.Controller, key string, value string) *URLHandler {
 	mockService := mock.NewMockService(ctrl)
-	mockService.EXPECT().MakeShortURL(gomock.Any(), key).Return(value, nil)
+	mockService.EXPECT().MakeShortURL(gomock.Any(), key, commontypes.LinkOptions{}).Return(value, nil)
 
-	return NewURLHandler(mockService)
+	return mustNewURLHandler(mockService, config.MockConfiguration)
 }
 
 func getHandlerMakeShortURLBatchMock(ctrl *gomock.Controller, key []commontypes.RecordToBatch, value []commontypes.BatchRecord) *URLHandler {
 	mockService := mock.NewMockService(ctrl)
 	mockService.EXPECT().MakeShortURLBatch(gomock.Any(), key).Return(value, nil)
 
-	return NewURLHandler(mockService)
+	return mustNewURLHandler(mockService, config.MockConfiguration)
 }
 
 func getDefaultHandler() *URLHandler {
@@ -59,6 +88,30 @@ func makeRequest(method string, path string, body []byte, contentType string, ro
 
 	return w.Result()
 }
+
+func makeRequestWithCookies(method string, path string, body []byte, contentType string, cookies []*http.Cookie, router http.Handler) *http.Response {
+	request := httptest.NewRequest(method, path, bytes.NewReader(body))
+	request.Header.Set("content-type", contentType)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+
+	return w.Result()
+}
+
+func makeAdminRequest(method string, path string, remoteIP string, router http.Handler) *http.Response {
+	request := httptest.NewRequest(method, path, nil)
+	if remoteIP != "" {
+		request.RemoteAddr = net.JoinHostPort(remoteIP, "40000")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+
+	return w.Result()
+}
+
 func TestGetTrueURL(t *testing.T) {
 	type testData struct {
 		method   string
@@ -443,3 +496,2014 @@ func TestShortenBatch(t *testing.T) {
 		})
 	}
 }
+
+func TestRedirectType(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestPayload string
+		createStatus   int
+		redirectStatus int
+		cacheControl   string
+	}{
+		{
+			name:           "Check default redirect type",
+			requestPayload: `{"url":"https://practicum.yandex.kz/"}`,
+			createStatus:   http.StatusCreated,
+			redirectStatus: http.StatusTemporaryRedirect,
+			cacheControl:   "private, no-store",
+		},
+		{
+			name:           "Check permanent redirect type",
+			requestPayload: `{"url":"https://practicum.yandex.kz/","redirect_type":301}`,
+			createStatus:   http.StatusCreated,
+			redirectStatus: http.StatusMovedPermanently,
+			cacheControl:   "public, max-age=86400",
+		},
+		{
+			name:           "Check unsupported redirect type",
+			requestPayload: `{"url":"https://practicum.yandex.kz/","redirect_type":303}`,
+			createStatus:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := getDefaultHandler().GetHTTPHandler(nil)
+
+			res := makeRequest(http.MethodPost, "/api/shorten", []byte(tt.requestPayload), "application/json", router)
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.createStatus, res.StatusCode)
+			if tt.createStatus != http.StatusCreated {
+				return
+			}
+
+			var responsePayload ShortenResponce
+			require.Nil(t, json.NewDecoder(res.Body).Decode(&responsePayload))
+
+			redirectRes := makeRequest(http.MethodGet, strings.TrimPrefix(responsePayload.Result, config.MockConfiguration.ShortURL), nil, "", router)
+			defer redirectRes.Body.Close()
+
+			assert.Equal(t, tt.redirectStatus, redirectRes.StatusCode)
+			assert.Equal(t, tt.cacheControl, redirectRes.Header.Get("Cache-Control"))
+			assert.Equal(t, "https://practicum.yandex.kz/", redirectRes.Header.Get("Location"))
+		})
+	}
+}
+
+func TestPassthrough(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestPayload string
+		visitPath      string
+		location       string
+	}{
+		{
+			name:           "Check query and path are dropped by default",
+			requestPayload: `{"url":"https://practicum.yandex.kz/docs?lang=en"}`,
+			visitPath:      "/guide?utm_source=mail",
+			location:       "https://practicum.yandex.kz/docs?lang=en",
+		},
+		{
+			name:           "Check query passthrough",
+			requestPayload: `{"url":"https://practicum.yandex.kz/docs?lang=en","pass_query":true}`,
+			visitPath:      "?utm_source=mail&lang=ru",
+			location:       "https://practicum.yandex.kz/docs?lang=en&utm_source=mail",
+		},
+		{
+			name:           "Check query passthrough with request precedence",
+			requestPayload: `{"url":"https://practicum.yandex.kz/docs?lang=en","pass_query":true,"query_precedence":"request"}`,
+			visitPath:      "?lang=ru",
+			location:       "https://practicum.yandex.kz/docs?lang=ru",
+		},
+		{
+			name:           "Check path passthrough",
+			requestPayload: `{"url":"https://practicum.yandex.kz/docs","pass_path":true}`,
+			visitPath:      "/guide/intro",
+			location:       "https://practicum.yandex.kz/docs/guide/intro",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := getDefaultHandler().GetHTTPHandler(nil)
+
+			res := makeRequest(http.MethodPost, "/api/shorten", []byte(tt.requestPayload), "application/json", router)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusCreated, res.StatusCode)
+
+			var responsePayload ShortenResponce
+			require.Nil(t, json.NewDecoder(res.Body).Decode(&responsePayload))
+
+			endpoint := strings.TrimPrefix(responsePayload.Result, config.MockConfiguration.ShortURL) + tt.visitPath
+			redirectRes := makeRequest(http.MethodGet, endpoint, nil, "", router)
+			defer redirectRes.Body.Close()
+
+			assert.Equal(t, http.StatusTemporaryRedirect, redirectRes.StatusCode)
+			assert.Equal(t, tt.location, redirectRes.Header.Get("Location"))
+		})
+	}
+}
+
+func TestUTM(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	shorten := func(requestPayload string) (int, string) {
+		res := makeRequest(http.MethodPost, "/api/shorten", []byte(requestPayload), "application/json", router)
+		defer res.Body.Close()
+
+		var responsePayload ShortenResponce
+		json.NewDecoder(res.Body).Decode(&responsePayload)
+		return res.StatusCode, strings.TrimPrefix(responsePayload.Result, config.MockConfiguration.ShortURL)
+	}
+
+	status, mailPath := shorten(`{"url":"https://practicum.yandex.kz/?utm_source=old","utm":{"utm_source":"mail","utm_campaign":"spring"}}`)
+	require.Equal(t, http.StatusCreated, status)
+
+	status, bannerPath := shorten(`{"url":"https://practicum.yandex.kz/?utm_source=old","utm":{"utm_source":"banner"}}`)
+	require.Equal(t, http.StatusCreated, status)
+	assert.NotEqual(t, mailPath, bannerPath)
+
+	status, _ = shorten(`{"url":"https://practicum.yandex.kz/","utm":{"source":"mail"}}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	res := makeRequest(http.MethodGet, mailPath, nil, "", router)
+	defer res.Body.Close()
+	assert.Equal(t, "https://practicum.yandex.kz/?utm_campaign=spring&utm_source=mail", res.Header.Get("Location"))
+
+	res = makeRequest(http.MethodGet, bannerPath, nil, "", router)
+	defer res.Body.Close()
+	assert.Equal(t, "https://practicum.yandex.kz/?utm_source=banner", res.Header.Get("Location"))
+}
+
+func TestDeviceRouting(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	requestPayload := `{"url":"https://practicum.yandex.kz/","device_rules":[` +
+		`{"os":"ios","url":"https://apps.apple.com/app/id1"},` +
+		`{"os":"android","url":"https://play.google.com/store/apps/details?id=kz.yandex"}]}`
+
+	res := makeRequest(http.MethodPost, "/api/shorten", []byte(requestPayload), "application/json", router)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	var responsePayload ShortenResponce
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&responsePayload))
+	endpoint := strings.TrimPrefix(responsePayload.Result, config.MockConfiguration.ShortURL)
+
+	tests := []struct {
+		name      string
+		userAgent string
+		location  string
+	}{
+		{
+			name:      "Check iOS goes to App Store",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148",
+			location:  "https://apps.apple.com/app/id1",
+		},
+		{
+			name:      "Check Android goes to Play",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36",
+			location:  "https://play.google.com/store/apps/details?id=kz.yandex",
+		},
+		{
+			name:      "Check desktop falls back to primary URL",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+			location:  "https://practicum.yandex.kz/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, endpoint, nil)
+			request.Header.Set("User-Agent", tt.userAgent)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+
+			redirectRes := w.Result()
+			defer redirectRes.Body.Close()
+
+			assert.Equal(t, tt.location, redirectRes.Header.Get("Location"))
+		})
+	}
+
+	res = makeRequest(http.MethodPost, "/api/shorten", []byte(`{"url":"https://practicum.yandex.kz/","device_rules":[{"os":"symbian","url":"https://example.com/"}]}`), "application/json", router)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestGeoRoutingWithoutDatabase(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	requestPayload := `{"url":"https://practicum.yandex.kz/","geo_rules":[{"countries":["de"],"url":"https://practicum.yandex.kz/de"}]}`
+
+	res := makeRequest(http.MethodPost, "/api/shorten", []byte(requestPayload), "application/json", router)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestStats(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	res := makeRequest(http.MethodPost, "/api/shorten", []byte(`{"url":"https://practicum.yandex.kz/stats"}`), "application/json", router)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	var responsePayload ShortenResponce
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&responsePayload))
+	endpoint := strings.TrimPrefix(responsePayload.Result, config.MockConfiguration.ShortURL)
+	cookies := res.Cookies()
+
+	for i := 0; i < 3; i++ {
+		res = makeRequest(http.MethodGet, endpoint, nil, "", router)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+	}
+
+	res = makeRequest(http.MethodGet, "/api/urls"+endpoint+"/stats", nil, "", router)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	res = makeRequestWithCookies(http.MethodGet, "/api/urls"+endpoint+"/stats", nil, "", cookies, router)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var stats StatsResponse
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&stats))
+	assert.Equal(t, int64(3), stats.Clicks)
+	assert.Empty(t, stats.Countries)
+	assert.False(t, stats.CreatedAt.IsZero())
+
+	res = makeRequestWithCookies(http.MethodGet, "/api/urls/unknown/stats", nil, "", cookies, router)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestPasswordProtected(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	res := makeRequest(http.MethodPost, "/api/shorten", []byte(`{"url":"https://practicum.yandex.kz/secret","password":"s3cret"}`), "application/json", router)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	var responsePayload ShortenResponce
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&responsePayload))
+	endpoint := strings.TrimPrefix(responsePayload.Result, config.MockConfiguration.ShortURL)
+
+	res = makeRequest(http.MethodPost, "/api/shorten", []byte(`{"url":"https://practicum.yandex.kz/secret"}`), "application/json", router)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		password       string
+		accept         string
+		form           url.Values
+		status         int
+		location       string
+		bodyContains   string
+		authenticate   bool
+		bodyNotContain string
+	}{
+		{
+			name:         "Check browser gets password form",
+			method:       http.MethodGet,
+			path:         endpoint,
+			accept:       "text/html",
+			status:       http.StatusUnauthorized,
+			bodyContains: `action="` + endpoint + `/unlock"`,
+		},
+		{
+			name:         "Check API client is asked for Basic auth",
+			method:       http.MethodGet,
+			path:         endpoint,
+			status:       http.StatusUnauthorized,
+			authenticate: true,
+		},
+		{
+			name:         "Check wrong Basic auth password",
+			method:       http.MethodGet,
+			path:         endpoint,
+			password:     "wrong",
+			accept:       "text/html",
+			status:       http.StatusUnauthorized,
+			bodyContains: "Wrong password",
+		},
+		{
+			name:     "Check right Basic auth password",
+			method:   http.MethodGet,
+			path:     endpoint,
+			password: "s3cret",
+			status:   http.StatusTemporaryRedirect,
+			location: "https://practicum.yandex.kz/secret",
+		},
+		{
+			name:     "Check unlock form",
+			method:   http.MethodPost,
+			path:     endpoint + "/unlock",
+			form:     url.Values{"password": {"s3cret"}},
+			status:   http.StatusSeeOther,
+			location: "https://practicum.yandex.kz/secret",
+		},
+		{
+			name:           "Check preview hides destination",
+			method:         http.MethodGet,
+			path:           endpoint + "+",
+			status:         http.StatusOK,
+			bodyContains:   "password protected",
+			bodyNotContain: "practicum.yandex.kz/secret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.form.Encode()))
+			if tt.form != nil {
+				request.Header.Set("content-type", "application/x-www-form-urlencoded")
+			}
+			if tt.password != "" {
+				request.SetBasicAuth("", tt.password)
+			}
+			if tt.accept != "" {
+				request.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+
+			res := w.Result()
+			defer res.Body.Close()
+			body, _ := io.ReadAll(res.Body)
+
+			assert.Equal(t, tt.status, res.StatusCode)
+			assert.Equal(t, tt.location, res.Header.Get("Location"))
+			assert.Equal(t, tt.authenticate, res.Header.Get("WWW-Authenticate") != "")
+			assert.Contains(t, string(body), tt.bodyContains)
+			if tt.bodyNotContain != "" {
+				assert.NotContains(t, string(body), tt.bodyNotContain)
+			}
+			if tt.status == http.StatusTemporaryRedirect {
+				assert.Equal(t, "private, no-store", res.Header.Get("Cache-Control"))
+			}
+		})
+	}
+
+	var lastStatus int
+	for i := 0; i < 10 && lastStatus != http.StatusTooManyRequests; i++ {
+		request := httptest.NewRequest(http.MethodGet, endpoint, nil)
+		request.SetBasicAuth("", "wrong")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		lastStatus = w.Result().StatusCode
+	}
+	assert.Equal(t, http.StatusTooManyRequests, lastStatus)
+
+	request := httptest.NewRequest(http.MethodGet, endpoint, nil)
+	request.SetBasicAuth("", "s3cret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	assert.Equal(t, http.StatusTooManyRequests, w.Result().StatusCode)
+	assert.NotEmpty(t, w.Result().Header.Get("Retry-After"))
+
+	// Other clients still get to the link.
+	request = httptest.NewRequest(http.MethodGet, endpoint, nil)
+	request.RemoteAddr = "192.0.2.2:40000"
+	request.SetBasicAuth("", "s3cret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Result().StatusCode)
+}
+
+func TestMaxClicks(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	shorten := func() string {
+		res := makeRequest(http.MethodPost, "/api/shorten", []byte(`{"url":"https://practicum.yandex.kz/invite","max_clicks":2}`), "application/json", router)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+
+		var responsePayload ShortenResponce
+		require.Nil(t, json.NewDecoder(res.Body).Decode(&responsePayload))
+		return strings.TrimPrefix(responsePayload.Result, config.MockConfiguration.ShortURL)
+	}
+
+	endpoint := shorten()
+	assert.NotEqual(t, endpoint, shorten())
+
+	for i := 0; i < 2; i++ {
+		res := makeRequest(http.MethodGet, endpoint, nil, "", router)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+		assert.Equal(t, "private, no-store", res.Header.Get("Cache-Control"))
+	}
+
+	res := makeRequest(http.MethodGet, endpoint, nil, "", router)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusGone, res.StatusCode)
+
+	res = makeRequest(http.MethodPost, "/api/shorten", []byte(`{"url":"https://practicum.yandex.kz/invite","max_clicks":-1}`), "application/json", router)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestMaxClicksConcurrent(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	res := makeRequest(http.MethodPost, "/api/shorten", []byte(`{"url":"https://practicum.yandex.kz/invite","max_clicks":5}`), "application/json", router)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	var responsePayload ShortenResponce
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&responsePayload))
+	endpoint := strings.TrimPrefix(responsePayload.Result, config.MockConfiguration.ShortURL)
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := makeRequest(http.MethodGet, endpoint, nil, "", router)
+			res.Body.Close()
+			statuses <- res.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	assert.Equal(t, map[int]int{http.StatusTemporaryRedirect: 5, http.StatusGone: 15}, counts)
+}
+
+func TestSchedule(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	hourLater := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	hourAgo := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name           string
+		requestPayload string
+		createStatus   int
+		redirectStatus int
+	}{
+		{
+			name:           "Check link before activation",
+			requestPayload: `{"url":"https://practicum.yandex.kz/launch","not_before":"` + hourLater + `"}`,
+			createStatus:   http.StatusCreated,
+			redirectStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Check active link",
+			requestPayload: `{"url":"https://practicum.yandex.kz/sale","not_before":"` + hourAgo + `","not_after":"` + hourLater + `"}`,
+			createStatus:   http.StatusCreated,
+			redirectStatus: http.StatusTemporaryRedirect,
+		},
+		{
+			name:           "Check not_after in the past",
+			requestPayload: `{"url":"https://practicum.yandex.kz/sale","not_after":"` + hourAgo + `"}`,
+			createStatus:   http.StatusBadRequest,
+		},
+		{
+			name:           "Check not_after before not_before",
+			requestPayload: `{"url":"https://practicum.yandex.kz/sale","not_before":"` + hourLater + `","not_after":"` + time.Now().Add(30*time.Minute).UTC().Format(time.RFC3339) + `"}`,
+			createStatus:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := makeRequest(http.MethodPost, "/api/shorten", []byte(tt.requestPayload), "application/json", router)
+			defer res.Body.Close()
+			require.Equal(t, tt.createStatus, res.StatusCode)
+
+			if tt.createStatus != http.StatusCreated {
+				return
+			}
+
+			var responsePayload ShortenResponce
+			require.Nil(t, json.NewDecoder(res.Body).Decode(&responsePayload))
+
+			res = makeRequest(http.MethodGet, strings.TrimPrefix(responsePayload.Result, config.MockConfiguration.ShortURL), nil, "", router)
+			defer res.Body.Close()
+			assert.Equal(t, tt.redirectStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestUpdateURL(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://practicum.yandex.kz/old"}`))
+	request.Header.Set("content-type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+
+	res := w.Result()
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	ownerCookies := res.Cookies()
+	require.NotEmpty(t, ownerCookies)
+
+	var responsePayload ShortenResponce
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&responsePayload))
+	id := strings.TrimPrefix(responsePayload.Result, config.MockConfiguration.ShortURL+"/")
+
+	send := func(method string, path string, body string, cookies []*http.Cookie) *http.Response {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("content-type", "application/json")
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w.Result()
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		cookies []*http.Cookie
+		status  int
+	}{
+		{
+			name:   "Check other user cannot edit",
+			method: http.MethodPatch,
+			path:   "/api/urls/" + id,
+			body:   `{"url":"https://practicum.yandex.kz/stolen"}`,
+			status: http.StatusForbidden,
+		},
+		{
+			name:    "Check invalid URL",
+			method:  http.MethodPatch,
+			path:    "/api/urls/" + id,
+			body:    `{"url":"javascript:alert(1)"}`,
+			cookies: ownerCookies,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "Check unknown link",
+			method:  http.MethodPatch,
+			path:    "/api/urls/unknown",
+			body:    `{"url":"https://practicum.yandex.kz/new"}`,
+			cookies: ownerCookies,
+			status:  http.StatusNotFound,
+		},
+		{
+			name:    "Check owner edits",
+			method:  http.MethodPatch,
+			path:    "/api/urls/" + id,
+			body:    `{"url":"https://practicum.yandex.kz/new"}`,
+			cookies: ownerCookies,
+			status:  http.StatusOK,
+		},
+		{
+			name:   "Check other user cannot read history",
+			method: http.MethodGet,
+			path:   "/api/urls/" + id + "/history",
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := send(tt.method, tt.path, tt.body, tt.cookies)
+			defer res.Body.Close()
+			assert.Equal(t, tt.status, res.StatusCode)
+		})
+	}
+
+	res = send(http.MethodGet, "/"+id, "", nil)
+	defer res.Body.Close()
+	assert.Equal(t, "https://practicum.yandex.kz/new", res.Header.Get("Location"))
+
+	res = send(http.MethodGet, "/api/urls/"+id+"/history", "", ownerCookies)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var history []HistoryResponseRecord
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&history))
+	require.Len(t, history, 1)
+	assert.Equal(t, "https://practicum.yandex.kz/old", history[0].OldURL)
+	assert.Equal(t, "https://practicum.yandex.kz/new", history[0].NewURL)
+	assert.NotEmpty(t, history[0].Editor)
+	assert.False(t, history[0].ChangedAt.IsZero())
+
+	res = send(http.MethodPost, "/api/shorten", `{"url":"https://practicum.yandex.kz/old"}`, nil)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&responsePayload))
+	assert.NotEqual(t, config.MockConfiguration.ShortURL+"/"+id, responsePayload.Result)
+}
+
+func TestListUserURLs(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	send := func(method string, path string, body string, cookies []*http.Cookie) *http.Response {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("content-type", "application/json")
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w.Result()
+	}
+
+	res := send(http.MethodPost, "/api/shorten", `{"url":"https://practicum.yandex.kz/a","title":"Bravo","tags":[" Promo ","promo","mail"]}`, nil)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	cookies := res.Cookies()
+	require.NotEmpty(t, cookies)
+
+	for _, body := range []string{
+		`{"url":"https://practicum.yandex.kz/b","title":"Alpha","tags":["promo"]}`,
+		`{"url":"https://practicum.yandex.kz/c","title":"Charlie","notes":"print campaign"}`,
+	} {
+		res := send(http.MethodPost, "/api/shorten", body, cookies)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+	}
+
+	res = send(http.MethodPost, "/api/shorten", `{"url":"https://practicum.yandex.kz/d","tags":[""]}`, cookies)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	list := func(query string, cookies []*http.Cookie) ListResponse {
+		res := send(http.MethodGet, "/api/user/urls"+query, "", cookies)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var responsePayload ListResponse
+		require.Nil(t, json.NewDecoder(res.Body).Decode(&responsePayload))
+		return responsePayload
+	}
+
+	titles := func(response ListResponse) []string {
+		result := []string{}
+		for _, item := range response.Items {
+			result = append(result, item.Title)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		titles []string
+	}{
+		{
+			name:   "Check newest first by default",
+			titles: []string{"Charlie", "Alpha", "Bravo"},
+		},
+		{
+			name:   "Check filter by tag",
+			query:  "?tag=PROMO&sort=title",
+			titles: []string{"Alpha", "Bravo"},
+		},
+		{
+			name:   "Check search over title",
+			query:  "?q=char",
+			titles: []string{"Charlie"},
+		},
+		{
+			name:   "Check search over destination",
+			query:  "?q=yandex.kz/b",
+			titles: []string{"Alpha"},
+		},
+		{
+			name:   "Check sort by title descending",
+			query:  "?sort=-title",
+			titles: []string{"Charlie", "Bravo", "Alpha"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := list(tt.query, cookies)
+			assert.Equal(t, tt.titles, titles(response))
+			assert.Empty(t, response.NextCursor)
+		})
+	}
+
+	response := list("?sort=title", cookies)
+	require.Len(t, response.Items, 3)
+	assert.Equal(t, []string{"promo", "mail"}, response.Items[1].Tags)
+	assert.Equal(t, "print campaign", response.Items[2].Notes)
+
+	first := list("?sort=title&limit=2", cookies)
+	assert.Equal(t, []string{"Alpha", "Bravo"}, titles(first))
+	require.NotEmpty(t, first.NextCursor)
+
+	second := list("?sort=title&limit=2&cursor="+first.NextCursor, cookies)
+	assert.Equal(t, []string{"Charlie"}, titles(second))
+	assert.Empty(t, second.NextCursor)
+
+	assert.Empty(t, list("", nil).Items)
+
+	for _, query := range []string{"?cursor=invalid", "?sort=unknown", "?limit=-1"} {
+		res := send(http.MethodGet, "/api/user/urls"+query, "", cookies)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, query)
+	}
+}
+
+func TestListURLs(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	var created []string
+	for i := 0; i < 5; i++ {
+		res := makeRequest(http.MethodPost, "/api/shorten", []byte(fmt.Sprintf(`{"url":"https://practicum.yandex.kz/%d"}`, i)), "application/json", router)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		created = append(created, fmt.Sprintf("https://practicum.yandex.kz/%d", i))
+	}
+
+	var listed []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3)
+
+		res := makeAdminRequest(http.MethodGet, "/api/admin/urls?limit=2&cursor="+cursor, "127.0.0.1", router)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var responsePayload AdminListResponse
+		require.Nil(t, json.NewDecoder(res.Body).Decode(&responsePayload))
+		assert.LessOrEqual(t, len(responsePayload.Items), 2)
+		for _, item := range responsePayload.Items {
+			assert.NotEmpty(t, item.UserID)
+			listed = append(listed, item.OriginalURL)
+		}
+
+		if responsePayload.NextCursor == "" {
+			break
+		}
+		cursor = responsePayload.NextCursor
+	}
+
+	assert.Equal(t, created, listed)
+
+	res := makeAdminRequest(http.MethodGet, "/api/admin/urls?cursor=invalid", "127.0.0.1", router)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestAdminAPI(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	res := makeRequest(http.MethodPost, "/api/shorten", []byte(`{"url":"https://practicum.yandex.kz/a"}`), "application/json", router)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	var responsePayload ShortenResponce
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&responsePayload))
+	endpoint := strings.TrimPrefix(responsePayload.Result, config.MockConfiguration.ShortURL)
+
+	res = makeRequest(http.MethodPost, "/api/shorten", []byte(`{"url":"https://practicum.yandex.kz/b"}`), "application/json", router)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		remoteIP string
+		status   int
+	}{
+		{
+			name:   "Check stats from a client outside of trusted subnet",
+			method: http.MethodGet,
+			path:   "/api/internal/stats",
+			status: http.StatusForbidden,
+		},
+		{
+			name:     "Check stats outside of trusted subnet",
+			method:   http.MethodGet,
+			path:     "/api/internal/stats",
+			remoteIP: "10.0.0.1",
+			status:   http.StatusForbidden,
+		},
+		{
+			name:     "Check listing outside of trusted subnet",
+			method:   http.MethodGet,
+			path:     "/api/admin/urls",
+			remoteIP: "10.0.0.1",
+			status:   http.StatusForbidden,
+		},
+		{
+			name:     "Check deletion outside of trusted subnet",
+			method:   http.MethodDelete,
+			path:     "/api/admin/urls" + endpoint,
+			remoteIP: "10.0.0.1",
+			status:   http.StatusForbidden,
+		},
+		{
+			name:     "Check deletion of unknown link",
+			method:   http.MethodDelete,
+			path:     "/api/admin/urls/unknown",
+			remoteIP: "127.0.0.1",
+			status:   http.StatusNotFound,
+		},
+		{
+			name:     "Check deletion",
+			method:   http.MethodDelete,
+			path:     "/api/admin/urls" + endpoint,
+			remoteIP: "127.0.0.1",
+			status:   http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := makeAdminRequest(tt.method, tt.path, tt.remoteIP, router)
+			defer res.Body.Close()
+			assert.Equal(t, tt.status, res.StatusCode)
+		})
+	}
+
+	res = makeRequest(http.MethodGet, endpoint, nil, "", router)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res = makeAdminRequest(http.MethodGet, "/api/internal/stats", "127.0.0.1", router)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var stats InternalStatsResponse
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&stats))
+	assert.Equal(t, InternalStatsResponse{URLs: 1, Users: 1}, stats)
+}
+
+func TestAdminAPIBehindProxy(t *testing.T) {
+	conf := *config.MockConfiguration
+	conf.TrustedProxies = []string{"10.0.0.0/8"}
+	router := mustNewURLHandler(service.NewShortURLService(storage.NewInMemoryStorage(storage.URLStorageMap{}), &conf), &conf).GetHTTPHandler(nil)
+
+	tests := []struct {
+		name     string
+		remoteIP string
+		realIP   string
+		status   int
+	}{
+		{
+			name:     "Check spoofed X-Real-IP from untrusted client",
+			remoteIP: "192.0.2.1",
+			realIP:   "127.0.0.1",
+			status:   http.StatusForbidden,
+		},
+		{
+			name:     "Check X-Real-IP set by trusted proxy",
+			remoteIP: "10.0.0.5",
+			realIP:   "127.0.0.1",
+			status:   http.StatusOK,
+		},
+		{
+			name:     "Check client outside of trusted subnet behind trusted proxy",
+			remoteIP: "10.0.0.5",
+			realIP:   "192.0.2.1",
+			status:   http.StatusForbidden,
+		},
+		{
+			name:     "Check trusted proxy without X-Real-IP",
+			remoteIP: "10.0.0.5",
+			status:   http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			request.RemoteAddr = net.JoinHostPort(tt.remoteIP, "40000")
+			if tt.realIP != "" {
+				request.Header.Set("X-Real-IP", tt.realIP)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.status, res.StatusCode)
+		})
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	send := func(method string, path string, body string, headers map[string]string, cookies []*http.Cookie) *http.Response {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("content-type", "application/json")
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w.Result()
+	}
+
+	res := send(http.MethodPost, "/api/keys", `{"name":"crm","scopes":["create","create"]}`, nil, nil)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	cookies := res.Cookies()
+	require.NotEmpty(t, cookies)
+
+	var apiKey APIKeyResponse
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&apiKey))
+	require.NotEmpty(t, apiKey.Key)
+	assert.Equal(t, []string{"create"}, apiKey.Scopes)
+
+	bearer := map[string]string{"Authorization": "Bearer " + apiKey.Key}
+
+	res = send(http.MethodPost, "/api/shorten", `{"url":"https://practicum.yandex.kz/","title":"From CRM"}`, bearer, nil)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Empty(t, res.Cookies())
+
+	var shortenPayload ShortenResponce
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&shortenPayload))
+	id := strings.TrimPrefix(shortenPayload.Result, config.MockConfiguration.ShortURL+"/")
+
+	res = send(http.MethodGet, "/api/user/urls", "", nil, cookies)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var listPayload ListResponse
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&listPayload))
+	require.Len(t, listPayload.Items, 1)
+	assert.Equal(t, "From CRM", listPayload.Items[0].Title)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		headers map[string]string
+		cookies []*http.Cookie
+		status  int
+	}{
+		{
+			name:    "Check key without read-stats scope cannot list",
+			method:  http.MethodGet,
+			path:    "/api/user/urls",
+			headers: bearer,
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "Check key without delete scope cannot delete",
+			method:  http.MethodDelete,
+			path:    "/api/urls/" + id,
+			headers: map[string]string{"X-API-Key": apiKey.Key},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "Check key cannot issue keys",
+			method:  http.MethodPost,
+			path:    "/api/keys",
+			body:    `{"scopes":["delete"]}`,
+			headers: bearer,
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "Check unknown key",
+			method:  http.MethodPost,
+			path:    "/api/shorten",
+			body:    `{"url":"https://practicum.yandex.kz/"}`,
+			headers: map[string]string{"X-API-Key": "usk_unknown"},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "Check unknown scope",
+			method:  http.MethodPost,
+			path:    "/api/keys",
+			body:    `{"scopes":["admin"]}`,
+			cookies: cookies,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:   "Check other user cannot revoke",
+			method: http.MethodDelete,
+			path:   "/api/keys/" + apiKey.ID,
+			status: http.StatusNotFound,
+		},
+		{
+			name:    "Check owner revokes",
+			method:  http.MethodDelete,
+			path:    "/api/keys/" + apiKey.ID,
+			cookies: cookies,
+			status:  http.StatusNoContent,
+		},
+		{
+			name:    "Check revoked key",
+			method:  http.MethodPost,
+			path:    "/api/shorten",
+			body:    `{"url":"https://practicum.yandex.kz/"}`,
+			headers: bearer,
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "Check owner deletes",
+			method:  http.MethodDelete,
+			path:    "/api/urls/" + id,
+			cookies: cookies,
+			status:  http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := send(tt.method, tt.path, tt.body, tt.headers, tt.cookies)
+			defer res.Body.Close()
+			assert.Equal(t, tt.status, res.StatusCode)
+		})
+	}
+
+	res = send(http.MethodGet, "/api/keys", "", nil, cookies)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var keys []APIKeyResponse
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&keys))
+	require.Len(t, keys, 1)
+	assert.Equal(t, apiKey.ID, keys[0].ID)
+	assert.Empty(t, keys[0].Key)
+	assert.NotNil(t, keys[0].RevokedAt)
+}
+
+func TestShortenOwnership(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	shorten := func(fullURL string, cookies []*http.Cookie) (*http.Response, string) {
+		request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"`+fullURL+`"}`))
+		request.Header.Set("content-type", "application/json")
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		res := w.Result()
+
+		var payload ShortenResponce
+		json.NewDecoder(res.Body).Decode(&payload)
+		res.Body.Close()
+		return res, payload.Result
+	}
+
+	res, ownerURL := shorten("https://practicum.yandex.kz/", nil)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	ownerCookies := res.Cookies()
+
+	// Clients without a cookie share the links of a URL.
+	res, anonymousURL := shorten("https://practicum.yandex.kz/", nil)
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+	assert.Equal(t, ownerURL, anonymousURL)
+
+	res, againURL := shorten("https://practicum.yandex.kz/", ownerCookies)
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+	assert.Equal(t, ownerURL, againURL)
+
+	res, _ = shorten("https://practicum.yandex.kz/other", nil)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	strangerCookies := res.Cookies()
+
+	res, strangerURL := shorten("https://practicum.yandex.kz/", strangerCookies)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.NotEqual(t, ownerURL, strangerURL)
+
+	res, againURL = shorten("https://practicum.yandex.kz/", strangerCookies)
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+	assert.Equal(t, strangerURL, againURL)
+}
+
+func TestShortenOptionsMakeLinks(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	res := makeRequest(http.MethodPost, "/api/shorten", []byte(`{"url":"https://practicum.yandex.kz/"}`), "application/json", router)
+	res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	cookies := res.Cookies()
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "Check other redirect type", body: `{"url":"https://practicum.yandex.kz/","redirect_type":301}`, status: http.StatusCreated},
+		{name: "Check query passthrough", body: `{"url":"https://practicum.yandex.kz/","pass_query":true}`, status: http.StatusCreated},
+		{name: "Check path passthrough", body: `{"url":"https://practicum.yandex.kz/","pass_path":true}`, status: http.StatusCreated},
+		{name: "Check title", body: `{"url":"https://practicum.yandex.kz/","title":"Practicum"}`, status: http.StatusCreated},
+		{name: "Check same title again", body: `{"url":"https://practicum.yandex.kz/","title":"Practicum"}`, status: http.StatusConflict},
+		{name: "Check tags", body: `{"url":"https://practicum.yandex.kz/","tags":["news"]}`, status: http.StatusCreated},
+		{name: "Check no options again", body: `{"url":"https://practicum.yandex.kz/"}`, status: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tt.body))
+			request.Header.Set("content-type", "application/json")
+			for _, cookie := range cookies {
+				request.AddCookie(cookie)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+
+			assert.Equal(t, tt.status, w.Result().StatusCode)
+		})
+	}
+}
+
+func TestShortenCanonicalization(t *testing.T) {
+	tests := []struct {
+		name         string
+		canonicalize bool
+		status       int
+	}{
+		{name: "Check other spelling is a new link by default", canonicalize: false, status: http.StatusCreated},
+		{name: "Check other spelling is the same link when canonicalized", canonicalize: true, status: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := *config.MockConfiguration
+			conf.CanonicalizeURLs = tt.canonicalize
+			router := mustNewURLHandler(service.NewShortURLService(storage.NewInMemoryStorage(storage.URLStorageMap{}), &conf), &conf).GetHTTPHandler(nil)
+
+			res := makeRequest(http.MethodPost, "/api/shorten", []byte(`{"url":"https://practicum.yandex.kz/?b=2&a=1"}`), "application/json", router)
+			var first ShortenResponce
+			require.Nil(t, json.NewDecoder(res.Body).Decode(&first))
+			res.Body.Close()
+			require.Equal(t, http.StatusCreated, res.StatusCode)
+
+			res = makeRequestWithCookies(http.MethodPost, "/api/shorten", []byte(`{"url":"HTTPS://Practicum.Yandex.KZ:443/?a=1&b=2"}`), "application/json", res.Cookies(), router)
+			var second ShortenResponce
+			require.Nil(t, json.NewDecoder(res.Body).Decode(&second))
+			res.Body.Close()
+
+			assert.Equal(t, tt.status, res.StatusCode)
+			assert.Equal(t, tt.canonicalize, first.Result == second.Result)
+		})
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	conf := *config.MockConfiguration
+	conf.QuotaLinksPerDay = 3
+	conf.QuotaActiveLinks = 10
+	conf.QuotaBatchSize = 2
+
+	inMemoryStorage := storage.NewInMemoryStorage(storage.URLStorageMap{})
+	router := mustNewURLHandler(service.NewShortURLService(inMemoryStorage, &conf), &conf).GetHTTPHandler(nil)
+
+	send := func(method string, path string, body string, cookies []*http.Cookie) *http.Response {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("content-type", "application/json")
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w.Result()
+	}
+
+	res := send(http.MethodPost, "/api/shorten", `{"url":"https://practicum.yandex.kz/a"}`, nil)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	cookies := res.Cookies()
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		quota  string
+	}{
+		{
+			name:   "Check rejected link does not count",
+			path:   "/api/shorten",
+			body:   `{"url":"not a url"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "Check existing link does not count",
+			path:   "/api/shorten",
+			body:   `{"url":"https://practicum.yandex.kz/a"}`,
+			status: http.StatusConflict,
+		},
+		{
+			name:   "Check batch over batch size",
+			path:   "/api/shorten/batch",
+			body:   `[{"correlation_id":"1","original_url":"https://practicum.yandex.kz/b"},{"correlation_id":"2","original_url":"https://practicum.yandex.kz/c"},{"correlation_id":"3","original_url":"https://practicum.yandex.kz/d"}]`,
+			status: http.StatusForbidden,
+			quota:  "batch_size",
+		},
+		{
+			name:   "Check batch within quotas",
+			path:   "/api/shorten/batch",
+			body:   `[{"correlation_id":"1","original_url":"https://practicum.yandex.kz/b"},{"correlation_id":"2","original_url":"https://practicum.yandex.kz/c"}]`,
+			status: http.StatusCreated,
+		},
+		{
+			name:   "Check links per day",
+			path:   "/api/shorten",
+			body:   `{"url":"https://practicum.yandex.kz/d"}`,
+			status: http.StatusTooManyRequests,
+			quota:  "links_per_day",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := send(http.MethodPost, tt.path, tt.body, cookies)
+			defer res.Body.Close()
+			assert.Equal(t, tt.status, res.StatusCode)
+
+			if tt.quota != "" {
+				body, err := io.ReadAll(res.Body)
+				require.Nil(t, err)
+				assert.Contains(t, string(body), tt.quota)
+			}
+			if tt.status == http.StatusTooManyRequests {
+				assert.NotEmpty(t, res.Header.Get("Retry-After"))
+			}
+		})
+	}
+
+	res = send(http.MethodGet, "/api/user/quota", "", cookies)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var quota QuotaResponse
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&quota))
+	require.NotNil(t, quota.LinksPerDay.Limit)
+	assert.Equal(t, int64(3), *quota.LinksPerDay.Limit)
+	assert.Equal(t, int64(3), quota.LinksPerDay.Used)
+	require.NotNil(t, quota.ActiveLinks)
+	assert.Equal(t, int64(3), quota.ActiveLinks.Used)
+	require.NotNil(t, quota.BatchSize)
+	assert.Equal(t, int64(2), *quota.BatchSize)
+	assert.True(t, quota.ResetsAt.After(time.Now()))
+	assert.Nil(t, quota.APIKey)
+
+	conf = *config.MockConfiguration
+	conf.QuotaActiveLinks = 1
+	router = mustNewURLHandler(service.NewShortURLService(storage.NewInMemoryStorage(storage.URLStorageMap{}), &conf), &conf).GetHTTPHandler(nil)
+
+	res = send(http.MethodPost, "/api/shorten", `{"url":"https://practicum.yandex.kz/a"}`, nil)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	res = send(http.MethodPost, "/api/shorten", `{"url":"https://practicum.yandex.kz/b"}`, res.Cookies())
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	require.Nil(t, err)
+	assert.Contains(t, string(body), "active_links")
+
+	conf = *config.MockConfiguration
+	conf.QuotaActiveLinks = 3
+	router = mustNewURLHandler(service.NewShortURLService(storage.NewInMemoryStorage(storage.URLStorageMap{}), &conf), &conf).GetHTTPHandler(nil)
+
+	res = send(http.MethodGet, "/api/user/quota", "", nil)
+	defer res.Body.Close()
+	cookies = res.Cookies()
+
+	var created atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := send(http.MethodPost, "/api/shorten", fmt.Sprintf(`{"url":"https://practicum.yandex.kz/%d"}`, i), cookies)
+			defer res.Body.Close()
+			if res.StatusCode == http.StatusCreated {
+				created.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(3), created.Load())
+}
+
+func TestBodyLimits(t *testing.T) {
+	conf := *config.MockConfiguration
+	conf.MaxBodySize = 256
+	conf.MaxDecompressedSize = 512
+	conf.MaxBatchItems = 2
+
+	router := mustNewURLHandler(service.NewShortURLService(storage.NewInMemoryStorage(storage.URLStorageMap{}), &conf), &conf).GetHTTPHandler(nil)
+
+	gzipped := func(body string) []byte {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		writer.Write([]byte(body))
+		writer.Close()
+		return buf.Bytes()
+	}
+
+	batchRecord := func(i int) string {
+		return fmt.Sprintf(`{"correlation_id":"%d","original_url":"https://practicum.yandex.kz/%d"}`, i, i)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        []byte
+		gzip        bool
+		status      int
+	}{
+		{
+			name:        "Check body over the limit",
+			path:        "/",
+			contentType: "text/plain",
+			body:        []byte("https://practicum.yandex.kz/" + strings.Repeat("a", 300)),
+			status:      http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "Check gzipped body within the limits",
+			path:        "/api/shorten",
+			contentType: "application/json",
+			body:        gzipped(`{"url":"https://practicum.yandex.kz/"}`),
+			gzip:        true,
+			status:      http.StatusCreated,
+		},
+		{
+			name:        "Check gzipped body over the decompressed limit",
+			path:        "/api/shorten",
+			contentType: "application/json",
+			body:        gzipped(`{"url":"https://practicum.yandex.kz/` + strings.Repeat("a", 1000) + `"}`),
+			gzip:        true,
+			status:      http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "Check batch within the limits",
+			path:        "/api/shorten/batch",
+			contentType: "application/json",
+			body:        []byte("[" + batchRecord(1) + "," + batchRecord(2) + "]"),
+			status:      http.StatusCreated,
+		},
+		{
+			name:        "Check batch over the item limit",
+			path:        "/api/shorten/batch",
+			contentType: "application/json",
+			body:        []byte("[" + batchRecord(1) + "," + batchRecord(2) + "," + batchRecord(3) + "]"),
+			status:      http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "Check batch not an array",
+			path:        "/api/shorten/batch",
+			contentType: "application/json",
+			body:        []byte(batchRecord(1)),
+			status:      http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewReader(tt.body))
+			request.Header.Set("content-type", tt.contentType)
+			if tt.gzip {
+				request.Header.Set("Content-Encoding", "gzip")
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.status, res.StatusCode)
+		})
+	}
+}
+
+func TestShortenStream(t *testing.T) {
+	conf := *config.MockConfiguration
+	conf.MaxBatchItems = 2
+	conf.QuotaLinksPerDay = 3
+
+	router := mustNewURLHandler(service.NewShortURLService(storage.NewInMemoryStorage(storage.URLStorageMap{}), &conf), &conf).GetHTTPHandler(nil)
+
+	body := strings.Join([]string{
+		`{"correlation_id":"1","original_url":"https://practicum.yandex.kz/1"}`,
+		`not a record`,
+		`{"correlation_id":"2","original_url":"not a url"}`,
+		``,
+		`{"correlation_id":"3","original_url":"https://practicum.yandex.kz/3"}`,
+		`{"correlation_id":"4","original_url":"https://practicum.yandex.kz/4"}`,
+		`{"correlation_id":"5","original_url":"https://practicum.yandex.kz/5"}`,
+	}, "\n")
+
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten/stream", strings.NewReader(body))
+	request.Header.Set("content-type", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	res := w.Result()
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/x-ndjson", res.Header.Get("content-type"))
+	assert.True(t, w.Flushed)
+
+	var records []ShortenStreamResponseRecord
+	decoder := json.NewDecoder(res.Body)
+	for decoder.More() {
+		var record ShortenStreamResponseRecord
+		require.Nil(t, decoder.Decode(&record))
+		records = append(records, record)
+	}
+
+	require.Len(t, records, 6)
+	assert.Equal(t, 2, records[0].Line)
+	assert.NotEmpty(t, records[0].Error)
+	assert.Equal(t, "1", records[1].CorrelationID)
+	assert.True(t, strings.HasPrefix(records[1].ShortURL, "http://localhost:8080/"))
+	assert.Empty(t, records[1].Error)
+	assert.Equal(t, ShortenStreamResponseRecord{CorrelationID: "2", Error: "not a valid URL"}, records[2])
+	assert.Equal(t, "3", records[3].CorrelationID)
+	assert.NotEmpty(t, records[3].ShortURL)
+	assert.Equal(t, "4", records[4].CorrelationID)
+	assert.NotEmpty(t, records[4].ShortURL)
+	assert.Contains(t, records[5].Error, "links_per_day")
+
+	request = httptest.NewRequest(http.MethodPost, "/api/shorten/stream", strings.NewReader(body))
+	request.Header.Set("content-type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func TestShortenStreamLimits(t *testing.T) {
+	gzipped := func(body string) []byte {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		writer.Write([]byte(body))
+		writer.Close()
+		return buf.Bytes()
+	}
+
+	streamRecord := func(i int) string {
+		return fmt.Sprintf(`{"correlation_id":"%d","original_url":"https://practicum.yandex.kz/%d"}`, i, i)
+	}
+
+	tests := []struct {
+		name          string
+		maxSize       int
+		maxLines      int
+		body          []byte
+		gzip          bool
+		status        int
+		expectedLinks int
+		expectedError string
+	}{
+		{
+			name:    "Check stream over the size limit",
+			maxSize: 1024,
+			body:    []byte(strings.Repeat(streamRecord(1)+"\n", 20)),
+			status:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:          "Check gzipped stream over the decompressed limit",
+			maxSize:       1024,
+			body:          gzipped(streamRecord(1) + "\n" + strings.Repeat(" ", 2000) + "\n" + streamRecord(2)),
+			gzip:          true,
+			status:        http.StatusOK,
+			expectedLinks: 1,
+			expectedError: "request body too large",
+		},
+		{
+			name:          "Check stream over the line limit",
+			maxLines:      2,
+			body:          []byte(streamRecord(1) + "\n" + streamRecord(2) + "\n" + streamRecord(3)),
+			status:        http.StatusOK,
+			expectedLinks: 2,
+			expectedError: "stream is limited to 2 lines",
+		},
+		{
+			name:          "Check stream with a too long line",
+			body:          []byte(streamRecord(1) + "\n" + strings.Repeat("a", streamMaxLineSize+1)),
+			status:        http.StatusOK,
+			expectedLinks: 1,
+			expectedError: "line is longer than",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := *config.MockConfiguration
+			conf.MaxStreamSize = tt.maxSize
+			conf.MaxStreamLines = tt.maxLines
+
+			router := mustNewURLHandler(service.NewShortURLService(storage.NewInMemoryStorage(storage.URLStorageMap{}), &conf), &conf).GetHTTPHandler(nil)
+
+			request := httptest.NewRequest(http.MethodPost, "/api/shorten/stream", bytes.NewReader(tt.body))
+			request.Header.Set("content-type", "application/x-ndjson")
+			if tt.gzip {
+				request.Header.Set("Content-Encoding", "gzip")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+
+			require.Equal(t, tt.status, res.StatusCode)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var records []ShortenStreamResponseRecord
+			decoder := json.NewDecoder(res.Body)
+			for decoder.More() {
+				var record ShortenStreamResponseRecord
+				require.Nil(t, decoder.Decode(&record))
+				records = append(records, record)
+			}
+
+			require.Len(t, records, tt.expectedLinks+1)
+			for _, record := range records[:tt.expectedLinks] {
+				assert.NotEmpty(t, record.ShortURL)
+			}
+			assert.Contains(t, records[tt.expectedLinks].Error, tt.expectedError)
+		})
+	}
+}
+
+func TestShortenBatchMixed(t *testing.T) {
+	conf := *config.MockConfiguration
+	conf.QuotaLinksPerDay = 10
+
+	router := mustNewURLHandler(service.NewShortURLService(storage.NewInMemoryStorage(storage.URLStorageMap{}), &conf), &conf).GetHTTPHandler(nil)
+
+	res := makeRequest(http.MethodPost, "/api/shorten", []byte(`{"url":"https://practicum.yandex.kz/a"}`), "application/json", router)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	cookies := res.Cookies()
+
+	var existing ShortenResponce
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&existing))
+
+	res = makeRequestWithCookies(http.MethodPost, "/api/shorten/batch", []byte(`[`+
+		`{"correlation_id":"1","original_url":"https://practicum.yandex.kz/a"},`+
+		`{"correlation_id":"2","original_url":"not a url"},`+
+		`{"correlation_id":"3","original_url":"https://practicum.yandex.kz/b"},`+
+		`{"correlation_id":"4","original_url":"https://practicum.yandex.kz/b"}]`), "application/json", cookies, router)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	var records []ShortenBatchResponceRecord
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&records))
+	require.Len(t, records, 4)
+	assert.Equal(t, ShortenBatchResponceRecord{CorrelationID: "1", ShortURL: existing.Result, Existing: true}, records[0])
+	assert.Equal(t, ShortenBatchResponceRecord{CorrelationID: "2", Error: "not a valid URL"}, records[1])
+	assert.Equal(t, "3", records[2].CorrelationID)
+	assert.NotEmpty(t, records[2].ShortURL)
+	assert.False(t, records[2].Existing)
+	assert.Equal(t, ShortenBatchResponceRecord{CorrelationID: "4", ShortURL: records[2].ShortURL, Existing: true}, records[3])
+
+	res = makeRequestWithCookies(http.MethodGet, "/api/user/urls", nil, "", cookies, router)
+	defer res.Body.Close()
+	var links ListResponse
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&links))
+	assert.Len(t, links.Items, 2)
+
+	res = makeRequestWithCookies(http.MethodPost, "/api/shorten/stream", []byte(strings.Join([]string{
+		`{"correlation_id":"1","original_url":"https://practicum.yandex.kz/b"}`,
+		`{"correlation_id":"2","original_url":"not a url"}`,
+		`{"correlation_id":"3","original_url":"https://practicum.yandex.kz/c"}`,
+	}, "\n")), "application/x-ndjson", cookies, router)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var streamRecords []ShortenStreamResponseRecord
+	decoder := json.NewDecoder(res.Body)
+	for decoder.More() {
+		var record ShortenStreamResponseRecord
+		require.Nil(t, decoder.Decode(&record))
+		streamRecords = append(streamRecords, record)
+	}
+	require.Len(t, streamRecords, 3)
+	assert.Equal(t, ShortenStreamResponseRecord{CorrelationID: "1", ShortURL: records[2].ShortURL, Existing: true}, streamRecords[0])
+	assert.Equal(t, ShortenStreamResponseRecord{CorrelationID: "2", Error: "not a valid URL"}, streamRecords[1])
+	assert.NotEmpty(t, streamRecords[2].ShortURL)
+	assert.False(t, streamRecords[2].Existing)
+
+	// Only the links made count towards the quota.
+	res = makeRequestWithCookies(http.MethodGet, "/api/user/quota", nil, "", cookies, router)
+	defer res.Body.Close()
+	var quota QuotaResponse
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&quota))
+	assert.Equal(t, int64(3), quota.LinksPerDay.Used)
+}
+
+func TestImportExport(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	body := strings.Join([]string{
+		`original_url,alias,tags,expires_at`,
+		`https://practicum.yandex.kz/a,promo,"News, promo",2099-01-01`,
+		`https://practicum.yandex.kz/b,,,`,
+		`not a url,,,`,
+		`https://practicum.yandex.kz/c,promo,,`,
+		`https://practicum.yandex.kz/d,,,tomorrow`,
+		`https://practicum.yandex.kz/a,promo,,`,
+		`https://practicum.yandex.kz/e,"bad,,`,
+	}, "\n")
+
+	request := httptest.NewRequest(http.MethodPost, "/api/import", strings.NewReader(body))
+	request.Header.Set("content-type", "text/csv; charset=utf-8")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	res := w.Result()
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	cookies := res.Cookies()
+
+	var report ImportResponse
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&report))
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 4, report.Failed)
+	require.Len(t, report.Rows, 7)
+
+	assert.Equal(t, ImportResponseRow{
+		Line:        2,
+		OriginalURL: "https://practicum.yandex.kz/a",
+		ShortURL:    "http://localhost:8080/promo",
+		Status:      importStatusCreated,
+	}, report.Rows[0])
+	assert.Equal(t, importStatusCreated, report.Rows[1].Status)
+
+	for i, line := range []int{4, 5, 6} {
+		row := report.Rows[i+2]
+		assert.Equal(t, line, row.Line)
+		assert.Equal(t, importStatusFailed, row.Status)
+		assert.NotEmpty(t, row.Error)
+	}
+	assert.Contains(t, report.Rows[3].Error, "alias is taken")
+	assert.Equal(t, ImportResponseRow{
+		Line:        7,
+		OriginalURL: "https://practicum.yandex.kz/a",
+		ShortURL:    "http://localhost:8080/promo",
+		Status:      importStatusExists,
+	}, report.Rows[5])
+	assert.Equal(t, 8, report.Rows[6].Line)
+	assert.Equal(t, importStatusFailed, report.Rows[6].Status)
+
+	request = httptest.NewRequest(http.MethodPost, "/api/import", strings.NewReader("url\nhttps://practicum.yandex.kz/a"))
+	request.Header.Set("content-type", "text/csv")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	export := func(format string) *http.Response {
+		request := httptest.NewRequest(http.MethodGet, "/api/export?format="+format, nil)
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w.Result()
+	}
+
+	res = export("csv")
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/csv", res.Header.Get("content-type"))
+
+	rows, err := csv.NewReader(res.Body).ReadAll()
+	require.Nil(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, []string{"original_url", "alias", "tags", "expires_at", "short_url", "title", "created_at", "clicks"}, rows[0])
+	assert.Equal(t, []string{"https://practicum.yandex.kz/a", "promo", "news,promo", "2099-01-01T00:00:00Z", "http://localhost:8080/promo"}, rows[1][:5])
+	assert.Equal(t, "https://practicum.yandex.kz/b", rows[2][0])
+	assert.Equal(t, "", rows[2][1])
+
+	res = export("json")
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var records []ExportRecord
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&records))
+	require.Len(t, records, 2)
+	assert.Equal(t, "promo", records[0].Alias)
+	assert.Equal(t, "", records[1].Alias)
+	assert.Equal(t, []string{"news", "promo"}, records[0].Tags)
+	require.NotNil(t, records[0].ExpiresAt)
+	assert.Nil(t, records[1].ExpiresAt)
+
+	res = export("xml")
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestExportStreamsPages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	first := commontypes.URLRecord{ShortURLKey: "a0c7ecc8", FullURL: "https://practicum.yandex.kz/a"}
+	second := commontypes.URLRecord{ShortURLKey: "b1d8fdd9", FullURL: "https://practicum.yandex.kz/b"}
+	w := httptest.NewRecorder()
+
+	mockService := mock.NewMockService(ctrl)
+	gomock.InOrder(
+		mockService.EXPECT().ListUserURLs(gomock.Any(), gomock.Any()).Return(commontypes.URLPage{
+			Records: []commontypes.URLRecord{first},
+			Next:    &commontypes.ListCursor{ShortURLKey: first.ShortURLKey},
+		}, nil),
+		mockService.EXPECT().ListUserURLs(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, commontypes.ListQuery) (commontypes.URLPage, error) {
+			// The first page is already sent when the second one is read.
+			assert.Contains(t, w.Body.String(), first.FullURL)
+			assert.True(t, w.Flushed)
+			return commontypes.URLPage{Records: []commontypes.URLRecord{second}}, nil
+		}),
+	)
+
+	router := mustNewURLHandler(mockService, config.MockConfiguration).GetHTTPHandler(nil)
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/export", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), second.FullURL)
+}
+
+func TestVariants(t *testing.T) {
+	router := getDefaultHandler().GetHTTPHandler(nil)
+
+	res := makeRequest(http.MethodPost, "/api/shorten", []byte(`{"url":"https://practicum.yandex.kz/"}`), "application/json", router)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	var responsePayload ShortenResponce
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&responsePayload))
+	endpoint := strings.TrimPrefix(responsePayload.Result, config.MockConfiguration.ShortURL)
+	cookies := res.Cookies()
+
+	variants := `[{"id":"a","url":"https://practicum.yandex.kz/a","weight":70},{"id":"b","url":"https://practicum.yandex.kz/b","weight":30}]`
+
+	res = makeRequestWithCookies(http.MethodPost, "/api/urls/unknown/variants", []byte(variants), "application/json", cookies, router)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res = makeRequest(http.MethodPost, "/api/urls"+endpoint+"/variants", []byte(`[{"url":"https://evil.example/","weight":100}]`), "application/json", router)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	res = makeRequestWithCookies(http.MethodPost, "/api/urls"+endpoint+"/variants", []byte(`[{"url":"https://practicum.yandex.kz/a","weight":0}]`), "application/json", cookies, router)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res = makeRequestWithCookies(http.MethodPost, "/api/urls"+endpoint+"/variants", []byte(variants), "application/json", cookies, router)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	request := httptest.NewRequest(http.MethodGet, endpoint, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	redirectRes := w.Result()
+	defer redirectRes.Body.Close()
+
+	require.Len(t, redirectRes.Cookies(), 1)
+	cookie := redirectRes.Cookies()[0]
+	assert.Equal(t, "https://practicum.yandex.kz/"+cookie.Value, redirectRes.Header.Get("Location"))
+
+	for i := 0; i < 5; i++ {
+		request := httptest.NewRequest(http.MethodGet, endpoint, nil)
+		request.AddCookie(&http.Cookie{Name: cookie.Name, Value: "b"})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		stickyRes := w.Result()
+		stickyRes.Body.Close()
+
+		assert.Equal(t, "https://practicum.yandex.kz/b", stickyRes.Header.Get("Location"))
+	}
+
+	res = makeRequest(http.MethodGet, "/api/urls"+endpoint+"/variants", nil, "", router)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	res = makeRequestWithCookies(http.MethodGet, "/api/urls"+endpoint+"/variants", nil, "", cookies, router)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var stats []VariantResponseRecord
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&stats))
+	require.Len(t, stats, 2)
+
+	clicks := map[string]int64{}
+	for _, v := range stats {
+		clicks[v.ID] = v.Clicks
+	}
+	assert.Equal(t, int64(6), clicks["a"]+clicks["b"])
+	assert.GreaterOrEqual(t, clicks["b"], int64(5))
+}
+
+func TestNewURLHandlerWithBrokenJWT(t *testing.T) {
+	conf := *config.MockConfiguration
+	conf.JWTAlgorithm = auth.AlgorithmHS256
+	conf.JWTSecretFile = filepath.Join(t.TempDir(), "missing-secret")
+
+	_, err := NewURLHandler(service.NewShortURLService(storage.NewInMemoryStorage(storage.URLStorageMap{}), &conf), &conf)
+	assert.Error(t, err)
+}
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name             string
+		requests         int
+		remoteAddrs      []string
+		expectedStatuses []int
+	}{
+		{
+			name:             "Check requests over burst are rejected",
+			requests:         3,
+			remoteAddrs:      []string{"10.0.0.1:1000", "10.0.0.1:1001", "10.0.0.1:1002"},
+			expectedStatuses: []int{http.StatusCreated, http.StatusCreated, http.StatusTooManyRequests},
+		},
+		{
+			name:             "Check clients are limited separately",
+			requests:         3,
+			remoteAddrs:      []string{"10.0.0.1:1000", "10.0.0.1:1001", "10.0.0.2:1000"},
+			expectedStatuses: []int{http.StatusCreated, http.StatusCreated, http.StatusCreated},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := *config.MockConfiguration
+			conf.RateLimitCreate = 1
+			conf.RateLimitCreateBurst = 2
+
+			inMemoryStorage := storage.NewInMemoryStorage(storage.URLStorageMap{})
+			router := mustNewURLHandler(service.NewShortURLService(inMemoryStorage, &conf), &conf).GetHTTPHandler(nil)
+
+			for i := 0; i < tt.requests; i++ {
+				request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(fmt.Sprintf("https://practicum.yandex.kz/%d", i)))
+				request.Header.Set("content-type", "text/plain")
+				request.RemoteAddr = tt.remoteAddrs[i]
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, request)
+
+				res := w.Result()
+				res.Body.Close()
+
+				assert.Equal(t, tt.expectedStatuses[i], res.StatusCode)
+				assert.NotEmpty(t, res.Header.Get("RateLimit-Limit"))
+				if res.StatusCode == http.StatusTooManyRequests {
+					assert.Equal(t, "60", res.Header.Get("Retry-After"))
+				}
+			}
+		})
+	}
+}
+
+func TestPreview(t *testing.T) {
+	record := commontypes.URLRecord{
+		ShortURLKey: "a0c7ecc8",
+		FullURL:     "https://practicum.yandex.kz/",
+		CreatedAt:   time.Date(2024, 8, 18, 12, 0, 0, 0, time.UTC),
+		Clicks:      42,
+	}
+
+	tests := []struct {
+		name     string
+		endpoint string
+	}{
+		{name: "Check preview with plus suffix", endpoint: "/a0c7ecc8+"},
+		{name: "Check preview with query param", endpoint: "/a0c7ecc8?preview=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			router := getHandlerGetURLRecordMock(ctrl, record.ShortURLKey, record).GetHTTPHandler(nil)
+			res := makeRequest(http.MethodGet, tt.endpoint, nil, "", router)
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			require.Nil(t, err)
+
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, "", res.Header.Get("Location"))
+			assert.Contains(t, res.Header.Get("content-type"), "text/html")
+			assert.Contains(t, string(body), record.FullURL)
+			assert.Contains(t, string(body), "http://localhost:8080/a0c7ecc8")
+			assert.Contains(t, string(body), "2024-08-18 12:00 UTC")
+			assert.Contains(t, string(body), "42")
+		})
+	}
+
+	t.Run("Check preview hides the destination of a click limited link", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		limited := record
+		limited.MaxClicks = 1
+		limited.ClicksLeft = 1
+
+		router := getHandlerGetURLRecordMock(ctrl, limited.ShortURLKey, limited).GetHTTPHandler(nil)
+		res := makeRequest(http.MethodGet, "/a0c7ecc8+", nil, "", router)
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		require.Nil(t, err)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NotContains(t, string(body), limited.FullURL)
+		assert.Contains(t, string(body), "http://localhost:8080/a0c7ecc8")
+	})
+
+	t.Run("Check preview hides the destination of a link not active yet", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		scheduled := record
+		scheduled.NotBefore = time.Now().Add(time.Hour)
+
+		router := getHandlerGetURLRecordMock(ctrl, scheduled.ShortURLKey, scheduled).GetHTTPHandler(nil)
+		res := makeRequest(http.MethodGet, "/a0c7ecc8+", nil, "", router)
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		require.Nil(t, err)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NotContains(t, string(body), scheduled.FullURL)
+		assert.Contains(t, string(body), "not active yet")
+	})
+}
+
+func TestQRCode(t *testing.T) {
+	record := commontypes.URLRecord{
+		ShortURLKey: "a0c7ecc8",
+		FullURL:     "https://practicum.yandex.kz/",
+	}
+
+	tests := []struct {
+		name        string
+		endpoint    string
+		status      int
+		contentType string
+	}{
+		{name: "Check default png", endpoint: "/a0c7ecc8/qr", status: http.StatusOK, contentType: "image/png"},
+		{name: "Check svg", endpoint: "/a0c7ecc8/qr?format=svg&size=512&margin=2&ec=H", status: http.StatusOK, contentType: "image/svg+xml"},
+		{name: "Check wrong format", endpoint: "/a0c7ecc8/qr?format=gif", status: http.StatusBadRequest},
+		{name: "Check wrong size", endpoint: "/a0c7ecc8/qr?size=100000", status: http.StatusBadRequest},
+		{name: "Check wrong error correction level", endpoint: "/a0c7ecc8/qr?ec=X", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			URLHandler := getDefaultHandler()
+			if tt.status == http.StatusOK {
+				URLHandler = getHandlerGetURLRecordMock(ctrl, record.ShortURLKey, record)
+			}
+
+			res := makeRequest(http.MethodGet, tt.endpoint, nil, "", URLHandler.GetHTTPHandler(nil))
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.status, res.StatusCode)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, tt.contentType, res.Header.Get("content-type"))
+			if tt.contentType == "image/png" {
+				img, err := png.Decode(res.Body)
+				require.Nil(t, err)
+				assert.Equal(t, 222, img.Bounds().Dx())
+			} else {
+				body, err := io.ReadAll(res.Body)
+				require.Nil(t, err)
+				assert.Contains(t, string(body), `width="512"`)
+			}
+		})
+	}
+}
+
+func TestShortenWithQRCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	router := getHandlerMakeShortURLMock(ctrl, "https://practicum.yandex.kz/", "http://localhost:8080/a0c7ecc8").GetHTTPHandler(nil)
+	res := makeRequest(http.MethodPost, "/api/shorten", []byte(`{"url":"https://practicum.yandex.kz/","qr":true}`), "application/json", router)
+	defer res.Body.Close()
+
+	var responsePayload ShortenResponce
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&responsePayload))
+
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "http://localhost:8080/a0c7ecc8", responsePayload.Result)
+	assert.True(t, strings.HasPrefix(responsePayload.QR, "data:image/png;base64,"))
+}
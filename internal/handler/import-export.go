@@ -0,0 +1,365 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+)
+
+const exportPageSize = 100
+
+const (
+	importStatusCreated = "created"
+	importStatusExists  = "exists"
+	importStatusFailed  = "failed"
+)
+
+// importColumns are the columns of the CSV files, original_url is the only required one.
+var importColumns = []string{"original_url", "alias", "tags", "expires_at"}
+
+type ImportResponse struct {
+	Created int                 `json:"created"`
+	Failed  int                 `json:"failed"`
+	Rows    []ImportResponseRow `json:"rows"`
+}
+
+// ImportResponseRow reports a row by its line in the CSV file.
+type ImportResponseRow struct {
+	Line        int    `json:"line"`
+	OriginalURL string `json:"original_url,omitempty"`
+	ShortURL    string `json:"short_url,omitempty"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+}
+
+type ExportRecord struct {
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	Alias       string     `json:"alias"`
+	Title       string     `json:"title,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Clicks      int64      `json:"clicks"`
+}
+
+// ImportURLs shortens every row of a CSV file and reports how each row went.
+func (handler *URLHandler) ImportURLs(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(res, "Not a POST requests", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("content-type")); mediaType != "text/csv" {
+		http.Error(res, "Not a \"text/csv\" content-type", http.StatusBadRequest)
+		return
+	}
+
+	defer req.Body.Close()
+	reader := csv.NewReader(req.Body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		writeBodyError(res, err)
+		return
+	}
+
+	columns, err := importColumnIndexes(header)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	responsePayload := ImportResponse{Rows: []ImportResponseRow{}}
+
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var row ImportResponseRow
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			row = ImportResponseRow{Line: parseErr.StartLine, Status: importStatusFailed, Error: parseErr.Err.Error()}
+		case err != nil:
+			writeBodyError(res, err)
+			logger.LogError(err)
+			return
+		default:
+			line, _ := reader.FieldPos(0)
+			row = handler.importRow(req, line, func(column string) string {
+				if i, ok := columns[column]; ok && i < len(fields) {
+					return strings.TrimSpace(fields[i])
+				}
+				return ""
+			})
+		}
+
+		if row.Status == importStatusFailed {
+			responsePayload.Failed++
+		} else if row.Status == importStatusCreated {
+			responsePayload.Created++
+		}
+		responsePayload.Rows = append(responsePayload.Rows, row)
+	}
+
+	writeJSON(res, http.StatusOK, responsePayload)
+}
+
+func (handler *URLHandler) importRow(req *http.Request, line int, field func(column string) string) ImportResponseRow {
+	row := ImportResponseRow{Line: line, OriginalURL: field("original_url")}
+
+	options := commontypes.LinkOptions{
+		Alias: field("alias"),
+		Tags: strings.FieldsFunc(field("tags"), func(r rune) bool {
+			return r == ',' || r == ';'
+		}),
+	}
+
+	if expiresAt := field("expires_at"); expiresAt != "" {
+		notAfter, err := parseExpiresAt(expiresAt)
+		if err != nil {
+			row.Status = importStatusFailed
+			row.Error = err.Error()
+			return row
+		}
+		options.NotAfter = notAfter
+	}
+
+	shortURL, serviceErr := handler.service.MakeShortURL(req.Context(), row.OriginalURL, options)
+	switch {
+	case serviceErr == nil:
+		row.Status = importStatusCreated
+		row.ShortURL = shortURL
+	case errors.Is(serviceErr, customerrors.ErrUniqueKeyConstrantViolation):
+		row.Status = importStatusExists
+		row.ShortURL = shortURL
+	default:
+		row.Status = importStatusFailed
+		row.Error = serviceErr.Error()
+	}
+
+	return row
+}
+
+func importColumnIndexes(header []string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		for _, column := range importColumns {
+			if name == column {
+				columns[column] = i
+			}
+		}
+	}
+
+	if _, ok := columns["original_url"]; !ok {
+		return nil, fmt.Errorf("the header must name the columns, original_url is required: %s", strings.Join(importColumns, ","))
+	}
+
+	return columns, nil
+}
+
+// parseExpiresAt takes RFC 3339 timestamps and dates, a date expires at its start in UTC.
+func parseExpiresAt(value string) (time.Time, error) {
+	if expiresAt, err := time.Parse(time.RFC3339, value); err == nil {
+		return expiresAt, nil
+	}
+
+	expiresAt, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expires_at %q is neither a RFC 3339 timestamp nor a date", value)
+	}
+	return expiresAt, nil
+}
+
+// ExportURLs streams all the links of the user as CSV or as a JSON array, page by page.
+func (handler *URLHandler) ExportURLs(res http.ResponseWriter, req *http.Request) {
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		http.Error(res, fmt.Sprintf("format %q is not supported, use csv or json", format), http.StatusBadRequest)
+		return
+	}
+
+	query := commontypes.ListQuery{Sort: commontypes.SortCreatedAsc, Limit: exportPageSize}
+
+	// The first page is read upfront, so that failures are still told by the status.
+	page, serviceErr := handler.service.ListUserURLs(req.Context(), query)
+	if serviceErr != nil {
+		statusCode := http.StatusBadRequest
+		if errors.Is(serviceErr, customerrors.ErrUnauthorized) {
+			statusCode = http.StatusUnauthorized
+		}
+		http.Error(res, serviceErr.Error(), statusCode)
+		return
+	}
+
+	var writer exportWriter
+	if format == "json" {
+		res.Header().Set("content-type", "application/json")
+		writer = &jsonExportWriter{writer: res}
+	} else {
+		res.Header().Set("content-type", "text/csv")
+		writer = &csvExportWriter{writer: csv.NewWriter(res)}
+	}
+	res.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"links.%s\"", format))
+	res.WriteHeader(http.StatusOK)
+
+	controller := http.NewResponseController(res)
+
+	for {
+		for _, record := range page.Records {
+			if err := writer.Write(handler.newExportRecord(record)); err != nil {
+				logger.LogError(err)
+				return
+			}
+		}
+
+		if page.Next == nil {
+			break
+		}
+
+		if err := writer.Flush(); err != nil {
+			logger.LogError(err)
+			return
+		}
+		controller.Flush()
+
+		query.After = page.Next
+		page, serviceErr = handler.service.ListUserURLs(req.Context(), query)
+		if serviceErr != nil {
+			// The export is left unfinished for the client to notice.
+			logger.LogError(serviceErr)
+			return
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		logger.LogError(err)
+	}
+}
+
+func (handler *URLHandler) newExportRecord(record commontypes.URLRecord) ExportRecord {
+	exportRecord := ExportRecord{
+		ShortURL:    handler.shortURLHost + "/" + record.ShortURLKey,
+		OriginalURL: record.FullURL,
+		Alias:       record.Alias,
+		Title:       record.Title,
+		Tags:        record.Tags,
+		CreatedAt:   record.CreatedAt,
+		Clicks:      record.Clicks,
+	}
+
+	if !record.NotAfter.IsZero() {
+		exportRecord.ExpiresAt = &record.NotAfter
+	}
+
+	return exportRecord
+}
+
+type exportWriter interface {
+	Write(record ExportRecord) error
+	// Flush passes the buffered records on to the response at the end of a page.
+	Flush() error
+	Close() error
+}
+
+// csvExportWriter starts with the import columns, so that exports can be imported back.
+type csvExportWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvExportWriter) Write(record ExportRecord) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	var expiresAt string
+	if record.ExpiresAt != nil {
+		expiresAt = record.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	return w.writer.Write([]string{
+		record.OriginalURL,
+		record.Alias,
+		strings.Join(record.Tags, ","),
+		expiresAt,
+		record.ShortURL,
+		record.Title,
+		record.CreatedAt.UTC().Format(time.RFC3339),
+		fmt.Sprint(record.Clicks),
+	})
+}
+
+func (w *csvExportWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+
+	return w.writer.Write(slices.Concat(importColumns, []string{"short_url", "title", "created_at", "clicks"}))
+}
+
+func (w *csvExportWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvExportWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+type jsonExportWriter struct {
+	writer  io.Writer
+	written int
+}
+
+func (w *jsonExportWriter) Write(record ExportRecord) error {
+	separator := ","
+	if w.written == 0 {
+		separator = "["
+	}
+
+	if _, err := io.WriteString(w.writer, separator); err != nil {
+		return err
+	}
+	w.written++
+
+	return json.NewEncoder(w.writer).Encode(record)
+}
+
+// Flush has nothing to do, records are written to the response as they come.
+func (w *jsonExportWriter) Flush() error {
+	return nil
+}
+
+func (w *jsonExportWriter) Close() error {
+	closing := "]"
+	if w.written == 0 {
+		closing = "[]"
+	}
+	_, err := io.WriteString(w.writer, closing)
+	return err
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+)
+
+var (
+	errInvalidCursor = errors.New("invalid cursor")
+	errInvalidLimit  = errors.New("limit must be a positive number")
+)
+
+type ListResponseRecord struct {
+	ShortURL    string    `json:"short_url"`
+	OriginalURL string    `json:"original_url"`
+	Title       string    `json:"title,omitempty"`
+	Notes       string    `json:"notes,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Clicks      int64     `json:"clicks"`
+}
+
+type AdminListResponseRecord struct {
+	ShortURL    string    `json:"short_url"`
+	OriginalURL string    `json:"original_url"`
+	UserID      string    `json:"user_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Clicks      int64     `json:"clicks"`
+}
+
+type AdminListResponse struct {
+	Items      []AdminListResponseRecord `json:"items"`
+	NextCursor string                    `json:"next_cursor,omitempty"`
+}
+
+type ListResponse struct {
+	Items      []ListResponseRecord `json:"items"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+func (handler *URLHandler) ListUserURLs(res http.ResponseWriter, req *http.Request) {
+	params := req.URL.Query()
+
+	query := commontypes.ListQuery{
+		Tag:    params.Get("tag"),
+		Search: params.Get("q"),
+		Sort:   params.Get("sort"),
+	}
+
+	limit, err := parseLimit(params.Get("limit"))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.Limit = limit
+
+	if cursor := params.Get("cursor"); cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.After = after
+	}
+
+	page, serviceErr := handler.service.ListUserURLs(req.Context(), query)
+	if serviceErr != nil {
+		statusCode := http.StatusBadRequest
+		if errors.Is(serviceErr, customerrors.ErrUnauthorized) {
+			statusCode = http.StatusUnauthorized
+		}
+		http.Error(res, serviceErr.Error(), statusCode)
+		return
+	}
+
+	responsePayload := ListResponse{Items: make([]ListResponseRecord, len(page.Records))}
+	for i, record := range page.Records {
+		responsePayload.Items[i] = ListResponseRecord{
+			ShortURL:    handler.shortURLHost + "/" + record.ShortURLKey,
+			OriginalURL: record.FullURL,
+			Title:       record.Title,
+			Notes:       record.Notes,
+			Tags:        record.Tags,
+			CreatedAt:   record.CreatedAt,
+			Clicks:      record.Clicks,
+		}
+	}
+
+	if page.Next != nil {
+		responsePayload.NextCursor = encodeCursor(*page.Next)
+	}
+
+	writeJSON(res, http.StatusOK, responsePayload)
+}
+
+func (handler *URLHandler) ListURLs(res http.ResponseWriter, req *http.Request) {
+	params := req.URL.Query()
+
+	limit, err := parseLimit(params.Get("limit"))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var after *commontypes.ListCursor
+	if cursor := params.Get("cursor"); cursor != "" {
+		if after, err = decodeCursor(cursor); err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, serviceErr := handler.service.ListURLs(req.Context(), after, limit)
+	if serviceErr != nil {
+		http.Error(res, serviceErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	responsePayload := AdminListResponse{Items: make([]AdminListResponseRecord, len(page.Records))}
+	for i, record := range page.Records {
+		responsePayload.Items[i] = AdminListResponseRecord{
+			ShortURL:    handler.shortURLHost + "/" + record.ShortURLKey,
+			OriginalURL: record.FullURL,
+			UserID:      record.UserID,
+			CreatedAt:   record.CreatedAt,
+			Clicks:      record.Clicks,
+		}
+	}
+
+	if page.Next != nil {
+		responsePayload.NextCursor = encodeCursor(*page.Next)
+	}
+
+	writeJSON(res, http.StatusOK, responsePayload)
+}
+
+func parseLimit(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return 0, errInvalidLimit
+	}
+	return limit, nil
+}
+
+// Cursors are opaque to clients, they pass back whatever the previous page returned.
+func encodeCursor(cursor commontypes.ListCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (*commontypes.ListCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var cursor commontypes.ListCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ShortURLKey == "" {
+		return nil, errInvalidCursor
+	}
+
+	return &cursor, nil
+}
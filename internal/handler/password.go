@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+)
+
+type passwordPage struct {
+	ShortURL string
+	Action   string
+	Path     string
+	Query    string
+	Error    string
+}
+
+// DoUnlock follows a password protected link with the password from the form.
+func (handler *URLHandler) DoUnlock(res http.ResponseWriter, req *http.Request) {
+	id := chi.URLParam(req, "id")
+
+	if err := req.ParseForm(); err != nil {
+		writeBodyError(res, err)
+		return
+	}
+
+	query, err := url.ParseQuery(req.PostForm.Get("query"))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	visit := commontypes.Visit{
+		Query:     query,
+		Path:      req.PostForm.Get("path"),
+		UserAgent: req.UserAgent(),
+		ClientIP:  handler.clientIPResolver.ClientIP(req),
+		Password:  req.PostForm.Get("password"),
+		Variant:   readVariantCookie(req, id),
+	}
+
+	redirect, err := handler.service.GetTrueURL(req.Context(), id, visit)
+	if err != nil {
+		handler.handleRedirectError(res, req, id, visit, err)
+		return
+	}
+
+	res.Header().Set("Cache-Control", "private, no-store")
+	if redirect.Variant != "" {
+		setVariantCookie(res, id, redirect.Variant)
+	}
+	// The browser must not repeat the POST to the destination.
+	http.Redirect(res, req, redirect.URL, http.StatusSeeOther)
+}
+
+// writePasswordForm serves the password form to browsers, other clients are asked
+// for Basic authentication.
+func (handler *URLHandler) writePasswordForm(res http.ResponseWriter, req *http.Request, id string, visit commontypes.Visit, message string) {
+	res.Header().Set("Cache-Control", "private, no-store")
+
+	if !strings.Contains(req.Header.Get("Accept"), "text/html") {
+		res.Header().Set("WWW-Authenticate", `Basic realm="short link", charset="UTF-8"`)
+		if message == "" {
+			message = "Password required"
+		}
+		http.Error(res, message, http.StatusUnauthorized)
+		return
+	}
+
+	page := passwordPage{
+		ShortURL: handler.shortURLHost + "/" + id,
+		Action:   "/" + id + "/unlock",
+		Path:     visit.Path,
+		Query:    visit.Query.Encode(),
+		Error:    message,
+	}
+
+	res.Header().Set("content-type", "text/html; charset=utf-8")
+	res.WriteHeader(http.StatusUnauthorized)
+	if err := templates.ExecuteTemplate(res, "password.html", page); err != nil {
+		logger.LogError(err)
+	}
+}
+
+// basicAuthPassword returns the password of the Authorization header, the user name
+// is ignored.
+func basicAuthPassword(req *http.Request) string {
+	_, password, ok := req.BasicAuth()
+	if !ok {
+		return ""
+	}
+	return password
+}
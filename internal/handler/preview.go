@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+)
+
+const previewSuffix = "+"
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+var templates = template.Must(template.ParseFS(templatesFS, "templates/*.html"))
+
+type previewPage struct {
+	ShortURL  string
+	FullURL   string
+	CreatedAt time.Time
+	Clicks    int64
+	Protected bool
+	// Limited is set for click limited links, whose destination is only
+	// revealed by spending a click.
+	Limited bool
+	// Scheduled is set for links not active yet, whose destination stays hidden
+	// until NotBefore.
+	Scheduled bool
+	// ClicksLeft is nil for links without a click limit.
+	ClicksLeft *int64
+	NotBefore  time.Time
+	NotAfter   time.Time
+}
+
+func isPreviewRequest(req *http.Request, id string) bool {
+	return strings.HasSuffix(id, previewSuffix) || req.URL.Query().Get("preview") == "1"
+}
+
+func (handler *URLHandler) DoPreview(res http.ResponseWriter, req *http.Request, id string) {
+	record, err := handler.service.GetURLRecord(req.Context(), strings.TrimSuffix(id, previewSuffix))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	page := previewPage{
+		ShortURL:  handler.shortURLHost + "/" + record.ShortURLKey,
+		CreatedAt: record.CreatedAt,
+		Clicks:    record.Clicks,
+		Protected: record.PasswordHash != "",
+		Limited:   record.MaxClicks > 0,
+		NotBefore: record.NotBefore,
+		NotAfter:  record.NotAfter,
+		Scheduled: time.Now().Before(record.NotBefore),
+	}
+
+	if record.MaxClicks > 0 {
+		page.ClicksLeft = &record.ClicksLeft
+	}
+
+	if !page.Protected && !page.Limited && !page.Scheduled {
+		page.FullURL = record.FullURL
+	}
+
+	res.Header().Set("content-type", "text/html; charset=utf-8")
+	res.WriteHeader(http.StatusOK)
+	if err := templates.ExecuteTemplate(res, "preview.html", page); err != nil {
+		logger.LogError(err)
+	}
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+	"github.com/with0p/golang-url-shortener.git/internal/qrcode"
+)
+
+const (
+	defaultQRSize   = 256
+	maxQRSize       = 2048
+	defaultQRMargin = 4
+	maxQRMargin     = 16
+)
+
+type qrOptions struct {
+	format string
+	size   int
+	margin int
+	level  qrcode.Level
+}
+
+func (handler *URLHandler) DoQRCode(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(res, "Not a GET requests", http.StatusMethodNotAllowed)
+		return
+	}
+
+	options, err := parseQROptions(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	record, err := handler.service.GetURLRecord(req.Context(), chi.URLParam(req, "id"))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	qr, err := qrcode.Encode(handler.shortURLHost+"/"+record.ShortURLKey, options.level)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		logger.LogError(err)
+		return
+	}
+
+	if options.format == "svg" {
+		res.Header().Set("content-type", "image/svg+xml")
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(qr.SVG(options.size, options.margin)))
+		return
+	}
+
+	image, err := qr.PNG(qrScale(qr, options.size, options.margin), options.margin)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		logger.LogError(err)
+		return
+	}
+
+	res.Header().Set("content-type", "image/png")
+	res.WriteHeader(http.StatusOK)
+	res.Write(image)
+}
+
+func parseQROptions(req *http.Request) (qrOptions, error) {
+	query := req.URL.Query()
+	options := qrOptions{
+		format: "png",
+		size:   defaultQRSize,
+		margin: defaultQRMargin,
+	}
+
+	if format := query.Get("format"); format != "" {
+		if format != "png" && format != "svg" {
+			return options, errors.New("format must be png or svg")
+		}
+		options.format = format
+	}
+
+	if size := query.Get("size"); size != "" {
+		value, err := strconv.Atoi(size)
+		if err != nil || value <= 0 || value > maxQRSize {
+			return options, errors.New("size must be between 1 and " + strconv.Itoa(maxQRSize))
+		}
+		options.size = value
+	}
+
+	if margin := query.Get("margin"); margin != "" {
+		value, err := strconv.Atoi(margin)
+		if err != nil || value < 0 || value > maxQRMargin {
+			return options, errors.New("margin must be between 0 and " + strconv.Itoa(maxQRMargin))
+		}
+		options.margin = value
+	}
+
+	level, err := qrcode.ParseLevel(query.Get("ec"))
+	if err != nil {
+		return options, err
+	}
+	options.level = level
+
+	return options, nil
+}
+
+// qrScale picks the number of pixels per module so that the image is not larger
+// than the requested size, unless a single pixel per module does not fit already.
+func qrScale(qr *qrcode.QRCode, size int, margin int) int {
+	return max(size/(qr.Size()+2*margin), 1)
+}
+
+func makeQRDataURI(shortURL string) (string, error) {
+	qr, err := qrcode.Encode(shortURL, qrcode.Medium)
+	if err != nil {
+		return "", err
+	}
+
+	image, err := qr.PNG(qrScale(qr, defaultQRSize, defaultQRMargin), defaultQRMargin)
+	if err != nil {
+		return "", err
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(image), nil
+}
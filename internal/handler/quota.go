@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+)
+
+type QuotaResponse struct {
+	LinksPerDay QuotaResponseRecord  `json:"links_per_day"`
+	ActiveLinks *QuotaResponseRecord `json:"active_links,omitempty"`
+	BatchSize   *int64               `json:"batch_size"`
+	ResetsAt    time.Time            `json:"resets_at"`
+	APIKey      *QuotaResponse       `json:"api_key,omitempty"`
+}
+
+// QuotaResponseRecord has a null limit when there is none.
+type QuotaResponseRecord struct {
+	Limit *int64 `json:"limit"`
+	Used  int64  `json:"used"`
+}
+
+func (handler *URLHandler) GetQuota(res http.ResponseWriter, req *http.Request) {
+	report, serviceErr := handler.service.GetQuota(req.Context())
+	if serviceErr != nil {
+		http.Error(res, serviceErr.Error(), ownerErrorStatus(serviceErr))
+		return
+	}
+
+	responsePayload := newQuotaResponse(report)
+	responsePayload.ActiveLinks = newQuotaResponseRecord(report.ActiveLinks)
+	if report.APIKey != nil {
+		responsePayload.APIKey = newQuotaResponse(*report.APIKey)
+	}
+
+	writeJSON(res, http.StatusOK, responsePayload)
+}
+
+func newQuotaResponse(report commontypes.QuotaReport) *QuotaResponse {
+	return &QuotaResponse{
+		LinksPerDay: *newQuotaResponseRecord(report.LinksPerDay),
+		BatchSize:   optionalLimit(report.BatchSize),
+		ResetsAt:    report.ResetsAt,
+	}
+}
+
+func newQuotaResponseRecord(quota commontypes.Quota) *QuotaResponseRecord {
+	return &QuotaResponseRecord{Limit: optionalLimit(quota.Limit), Used: quota.Used}
+}
+
+func optionalLimit(limit int64) *int64 {
+	if limit <= 0 {
+		return nil
+	}
+	return &limit
+}
+
+// writeQuotaError answers 429 when the quota renews over time and 403 otherwise, it
+// tells whether the error was a quota one.
+func writeQuotaError(res http.ResponseWriter, err error) bool {
+	var quotaErr *customerrors.QuotaError
+	if !errors.As(err, &quotaErr) {
+		return false
+	}
+
+	statusCode := http.StatusForbidden
+	if quotaErr.RetryAfter > 0 {
+		res.Header().Set("Retry-After", strconv.Itoa(int(quotaErr.RetryAfter.Seconds())+1))
+		statusCode = http.StatusTooManyRequests
+	}
+
+	http.Error(res, quotaErr.Error(), statusCode)
+	return true
+}
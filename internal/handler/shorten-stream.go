@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+)
+
+// streamChunkSize is the most records shortened in one go, it is lowered to the batch
+// size limits of the user.
+const streamChunkSize = 500
+
+// streamMaxLineSize is the longest line of a stream, far more than a record needs.
+const streamMaxLineSize = 64 << 10
+
+// ShortenStreamResponseRecord is a line of the response. Lines which are not a record
+// are reported by their number.
+type ShortenStreamResponseRecord struct {
+	CorrelationID string `json:"correlation_id,omitempty"`
+	ShortURL      string `json:"short_url,omitempty"`
+	Existing      bool   `json:"existing,omitempty"`
+	Line          int    `json:"line,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// ShortenStream shortens a NDJSON stream of batch records chunk by chunk, writing the
+// results of every chunk as NDJSON once it is stored.
+func (handler *URLHandler) ShortenStream(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(res, "Not a POST requests", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if req.Header.Get("content-type") != "application/x-ndjson" {
+		http.Error(res, "Not a \"application/x-ndjson\" content-type", http.StatusBadRequest)
+		return
+	}
+
+	defer req.Body.Close()
+
+	// The request is still being read while the results are sent.
+	controller := http.NewResponseController(res)
+	controller.EnableFullDuplex()
+
+	res.Header().Set("content-type", "application/x-ndjson")
+	res.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(res)
+	chunkSize := handler.streamChunkSize(req.Context())
+	chunk := make([]commontypes.RecordToBatch, 0, chunkSize)
+
+	shortenChunk := func() bool {
+		if len(chunk) == 0 {
+			return true
+		}
+
+		records, err := handler.service.MakeShortURLBatch(req.Context(), chunk)
+		if err != nil {
+			encoder.Encode(ShortenStreamResponseRecord{Error: err.Error()})
+			logger.LogError(err)
+			return false
+		}
+
+		for _, record := range records {
+			batchRecord := newShortenBatchResponceRecord(record)
+			encoder.Encode(ShortenStreamResponseRecord{
+				CorrelationID: batchRecord.CorrelationID,
+				ShortURL:      batchRecord.ShortURL,
+				Existing:      batchRecord.Existing,
+				Error:         batchRecord.Error,
+			})
+		}
+
+		chunk = chunk[:0]
+		controller.Flush()
+		return true
+	}
+
+	scanner := bufio.NewScanner(req.Body)
+	scanner.Buffer(make([]byte, 0, 4096), streamMaxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if handler.maxStreamLines > 0 && line > handler.maxStreamLines {
+			if shortenChunk() {
+				encoder.Encode(ShortenStreamResponseRecord{Line: line, Error: fmt.Sprintf("stream is limited to %d lines", handler.maxStreamLines)})
+			}
+			return
+		}
+
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var record ShortenBatchRequestRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			encoder.Encode(ShortenStreamResponseRecord{Line: line, Error: err.Error()})
+			continue
+		}
+
+		chunk = append(chunk, commontypes.RecordToBatch{
+			ID:      record.CorrelationID,
+			FullURL: record.OriginalURL,
+		})
+
+		if len(chunk) == chunkSize && !shortenChunk() {
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		shortenChunk()
+		if errors.Is(err, bufio.ErrTooLong) {
+			encoder.Encode(ShortenStreamResponseRecord{Line: line + 1, Error: fmt.Sprintf("line is longer than %d bytes", streamMaxLineSize)})
+			return
+		}
+		encoder.Encode(ShortenStreamResponseRecord{Error: err.Error()})
+		logger.LogError(err)
+		return
+	}
+
+	shortenChunk()
+}
+
+// streamChunkSize keeps the chunks within the batch limits, so that a stream is only
+// stopped by the quotas on the number of links.
+func (handler *URLHandler) streamChunkSize(ctx context.Context) int {
+	size := streamChunkSize
+	if handler.maxBatchItems > 0 && handler.maxBatchItems < size {
+		size = handler.maxBatchItems
+	}
+
+	report, err := handler.service.GetQuota(ctx)
+	if err != nil {
+		return size
+	}
+
+	for _, limit := range []int64{report.BatchSize, apiKeyBatchSize(report)} {
+		if limit > 0 && limit < int64(size) {
+			size = int(limit)
+		}
+	}
+
+	return size
+}
+
+func apiKeyBatchSize(report commontypes.QuotaReport) int64 {
+	if report.APIKey == nil {
+		return 0
+	}
+	return report.APIKey.BatchSize
+}
@@ -3,8 +3,10 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
 	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
@@ -12,11 +14,40 @@ import (
 )
 
 type ShortenRequest struct {
-	URL string `json:"url"`
+	URL             string              `json:"url"`
+	QR              bool                `json:"qr,omitempty"`
+	RedirectType    int                 `json:"redirect_type,omitempty"`
+	PassQuery       bool                `json:"pass_query,omitempty"`
+	QueryPrecedence string              `json:"query_precedence,omitempty"`
+	PassPath        bool                `json:"pass_path,omitempty"`
+	UTM             map[string]string   `json:"utm,omitempty"`
+	DeviceRules     []ShortenDeviceRule `json:"device_rules,omitempty"`
+	GeoRules        []ShortenGeoRule    `json:"geo_rules,omitempty"`
+	Password        string              `json:"password,omitempty"`
+	MaxClicks       int64               `json:"max_clicks,omitempty"`
+	NotBefore       *time.Time          `json:"not_before,omitempty"`
+	NotAfter        *time.Time          `json:"not_after,omitempty"`
+	Title           string              `json:"title,omitempty"`
+	Notes           string              `json:"notes,omitempty"`
+	Tags            []string            `json:"tags,omitempty"`
+	Alias           string              `json:"alias,omitempty"`
+}
+
+type ShortenDeviceRule struct {
+	OS     string `json:"os,omitempty"`
+	Device string `json:"device,omitempty"`
+	Bot    *bool  `json:"bot,omitempty"`
+	URL    string `json:"url"`
+}
+
+type ShortenGeoRule struct {
+	Countries []string `json:"countries"`
+	URL       string   `json:"url"`
 }
 
 type ShortenResponce struct {
 	Result string `json:"result"`
+	QR     string `json:"qr,omitempty"`
 }
 
 type ShortenBatchRequestRecord struct {
@@ -24,9 +55,65 @@ type ShortenBatchRequestRecord struct {
 	OriginalURL   string `json:"original_url"`
 }
 
+// ShortenBatchResponceRecord is the result of a record of the batch. A link which
+// exists already is reported as existing, a record which could not be shortened has
+// an error and no short URL.
 type ShortenBatchResponceRecord struct {
 	CorrelationID string `json:"correlation_id"`
 	ShortURL      string `json:"short_url"`
+	Existing      bool   `json:"existing,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+func newShortenBatchResponceRecord(record commontypes.BatchRecord) ShortenBatchResponceRecord {
+	responseRecord := ShortenBatchResponceRecord{CorrelationID: record.ID}
+
+	switch {
+	case record.Err == nil:
+		responseRecord.ShortURL = record.ShortURL
+	case errors.Is(record.Err, customerrors.ErrUniqueKeyConstrantViolation):
+		responseRecord.ShortURL = record.ShortURL
+		responseRecord.Existing = true
+	default:
+		responseRecord.Error = record.Err.Error()
+	}
+
+	return responseRecord
+}
+
+var errTooManyBatchItems = errors.New("too many records in the batch")
+
+// decodeBatch reads the JSON array of records one by one, so an oversized batch is
+// refused once it has more than maxItems records rather than after reading it all.
+func decodeBatch(body io.Reader, maxItems int) ([]ShortenBatchRequestRecord, error) {
+	decoder := json.NewDecoder(body)
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token != json.Delim('[') {
+		return nil, errors.New("not a JSON array")
+	}
+
+	var records []ShortenBatchRequestRecord
+	for decoder.More() {
+		if maxItems > 0 && len(records) == maxItems {
+			return nil, fmt.Errorf("%w, the limit is %d", errTooManyBatchItems, maxItems)
+		}
+
+		var record ShortenBatchRequestRecord
+		if err := decoder.Decode(&record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
 }
 
 func (handler *URLHandler) Shorten(res http.ResponseWriter, req *http.Request) {
@@ -43,7 +130,7 @@ func (handler *URLHandler) Shorten(res http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 	body, bodyReadError := io.ReadAll(req.Body)
 	if bodyReadError != nil {
-		http.Error(res, bodyReadError.Error(), http.StatusBadRequest)
+		writeBodyError(res, bodyReadError)
 		logger.LogError(bodyReadError)
 		return
 	}
@@ -58,9 +145,56 @@ func (handler *URLHandler) Shorten(res http.ResponseWriter, req *http.Request) {
 
 	statusCode := http.StatusCreated
 
-	shortURL, serviceErr := handler.service.MakeShortURL(req.Context(), requstPayload.URL)
+	options := commontypes.LinkOptions{
+		RedirectType:    requstPayload.RedirectType,
+		PassQuery:       requstPayload.PassQuery,
+		QueryPrecedence: requstPayload.QueryPrecedence,
+		PassPath:        requstPayload.PassPath,
+		UTM:             requstPayload.UTM,
+		Password:        requstPayload.Password,
+		MaxClicks:       requstPayload.MaxClicks,
+		Title:           requstPayload.Title,
+		Notes:           requstPayload.Notes,
+		Tags:            requstPayload.Tags,
+		Alias:           requstPayload.Alias,
+	}
+
+	for _, r := range requstPayload.DeviceRules {
+		options.DeviceRules = append(options.DeviceRules, commontypes.DeviceRule{
+			OS:     r.OS,
+			Device: r.Device,
+			Bot:    r.Bot,
+			URL:    r.URL,
+		})
+	}
+
+	for _, r := range requstPayload.GeoRules {
+		options.GeoRules = append(options.GeoRules, commontypes.GeoRule{
+			Countries: r.Countries,
+			URL:       r.URL,
+		})
+	}
+
+	if requstPayload.NotBefore != nil {
+		options.NotBefore = *requstPayload.NotBefore
+	}
+
+	if requstPayload.NotAfter != nil {
+		options.NotAfter = *requstPayload.NotAfter
+	}
+
+	shortURL, serviceErr := handler.service.MakeShortURL(req.Context(), requstPayload.URL, options)
 
 	if serviceErr != nil {
+		if writeQuotaError(res, serviceErr) {
+			return
+		}
+
+		if errors.Is(serviceErr, customerrors.ErrAliasTaken) {
+			http.Error(res, serviceErr.Error(), http.StatusConflict)
+			return
+		}
+
 		if errors.Is(serviceErr, customerrors.ErrUniqueKeyConstrantViolation) {
 			statusCode = http.StatusConflict
 		} else {
@@ -73,6 +207,16 @@ func (handler *URLHandler) Shorten(res http.ResponseWriter, req *http.Request) {
 		Result: shortURL,
 	}
 
+	if requstPayload.QR {
+		qr, err := makeQRDataURI(shortURL)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			logger.LogError(err)
+			return
+		}
+		responsePayload.QR = qr
+	}
+
 	response, err := json.Marshal(responsePayload)
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusBadRequest)
@@ -97,17 +241,14 @@ func (handler *URLHandler) ShortenBatch(res http.ResponseWriter, req *http.Reque
 	}
 
 	defer req.Body.Close()
-	body, bodyReadError := io.ReadAll(req.Body)
-	if bodyReadError != nil {
-		http.Error(res, bodyReadError.Error(), http.StatusBadRequest)
-		logger.LogError(bodyReadError)
-		return
-	}
-
-	var requestPayload []ShortenBatchRequestRecord
-	if err := json.Unmarshal(body, &requestPayload); err != nil {
-		http.Error(res, err.Error(), http.StatusBadRequest)
-		logger.LogError(err)
+	requestPayload, decodeErr := decodeBatch(req.Body, handler.maxBatchItems)
+	if decodeErr != nil {
+		if errors.Is(decodeErr, errTooManyBatchItems) {
+			http.Error(res, decodeErr.Error(), http.StatusRequestEntityTooLarge)
+		} else {
+			writeBodyError(res, decodeErr)
+		}
+		logger.LogError(decodeErr)
 		return
 	}
 
@@ -121,6 +262,9 @@ func (handler *URLHandler) ShortenBatch(res http.ResponseWriter, req *http.Reque
 
 	responsePayloadData, batchError := handler.service.MakeShortURLBatch(req.Context(), dataToBatch)
 	if batchError != nil {
+		if writeQuotaError(res, batchError) {
+			return
+		}
 		http.Error(res, batchError.Error(), http.StatusBadRequest)
 		logger.LogError(batchError)
 		return
@@ -128,10 +272,7 @@ func (handler *URLHandler) ShortenBatch(res http.ResponseWriter, req *http.Reque
 
 	responsePayload := make([]ShortenBatchResponceRecord, len(responsePayloadData))
 	for i, r := range responsePayloadData {
-		responsePayload[i] = ShortenBatchResponceRecord{
-			CorrelationID: r.ID,
-			ShortURL:      r.ShortURL,
-		}
+		responsePayload[i] = newShortenBatchResponceRecord(r)
 	}
 
 	response, err := json.Marshal(responsePayload)
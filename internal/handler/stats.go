@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type StatsResponse struct {
+	CreatedAt time.Time        `json:"created_at"`
+	Clicks    int64            `json:"clicks"`
+	Variants  map[string]int64 `json:"variants"`
+	Countries map[string]int64 `json:"countries"`
+}
+
+func (handler *URLHandler) GetStats(res http.ResponseWriter, req *http.Request) {
+	record, err := handler.service.GetOwnedURLRecord(req.Context(), chi.URLParam(req, "id"))
+	if err != nil {
+		http.Error(res, err.Error(), ownerErrorStatus(err))
+		return
+	}
+
+	responsePayload := StatsResponse{
+		CreatedAt: record.CreatedAt,
+		Clicks:    record.Clicks,
+		Variants:  record.VariantClicks,
+		Countries: record.CountryClicks,
+	}
+
+	if responsePayload.Variants == nil {
+		responsePayload.Variants = map[string]int64{}
+	}
+
+	if responsePayload.Countries == nil {
+		responsePayload.Countries = map[string]int64{}
+	}
+
+	writeJSON(res, http.StatusOK, responsePayload)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+)
+
+const variantCookieMaxAge = 30 * 24 * 60 * 60
+
+type VariantRequestRecord struct {
+	ID     string `json:"id,omitempty"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+type VariantResponseRecord struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+	Clicks int64  `json:"clicks"`
+}
+
+func (handler *URLHandler) SetVariants(res http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("content-type") != "application/json" {
+		http.Error(res, "Not a \"application/json\" content-type", http.StatusBadRequest)
+		return
+	}
+
+	defer req.Body.Close()
+	body, bodyReadError := io.ReadAll(req.Body)
+	if bodyReadError != nil {
+		writeBodyError(res, bodyReadError)
+		logger.LogError(bodyReadError)
+		return
+	}
+
+	var requestPayload []VariantRequestRecord
+	if err := json.Unmarshal(body, &requestPayload); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		logger.LogError(err)
+		return
+	}
+
+	variants := make([]commontypes.Variant, len(requestPayload))
+	for i, r := range requestPayload {
+		variants[i] = commontypes.Variant{
+			ID:     r.ID,
+			URL:    r.URL,
+			Weight: r.Weight,
+		}
+	}
+
+	saved, serviceErr := handler.service.SetVariants(req.Context(), chi.URLParam(req, "id"), variants)
+	if serviceErr != nil {
+		http.Error(res, serviceErr.Error(), ownerErrorStatus(serviceErr))
+		return
+	}
+
+	writeVariants(res, saved, nil)
+}
+
+func (handler *URLHandler) GetVariants(res http.ResponseWriter, req *http.Request) {
+	record, err := handler.service.GetOwnedURLRecord(req.Context(), chi.URLParam(req, "id"))
+	if err != nil {
+		http.Error(res, err.Error(), ownerErrorStatus(err))
+		return
+	}
+
+	writeVariants(res, record.Variants, record.VariantClicks)
+}
+
+func writeVariants(res http.ResponseWriter, variants []commontypes.Variant, clicks map[string]int64) {
+	responsePayload := make([]VariantResponseRecord, len(variants))
+	for i, v := range variants {
+		responsePayload[i] = VariantResponseRecord{
+			ID:     v.ID,
+			URL:    v.URL,
+			Weight: v.Weight,
+			Clicks: clicks[v.ID],
+		}
+	}
+
+	writeJSON(res, http.StatusOK, responsePayload)
+}
+
+func variantCookieName(id string) string {
+	return "variant_" + id
+}
+
+func readVariantCookie(req *http.Request, id string) string {
+	cookie, err := req.Cookie(variantCookieName(id))
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func setVariantCookie(res http.ResponseWriter, id string, variant string) {
+	http.SetCookie(res, &http.Cookie{
+		Name:     variantCookieName(id),
+		Value:    variant,
+		Path:     "/" + id,
+		MaxAge:   variantCookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -14,7 +14,7 @@ type extendedResponseWriter struct {
 
 func (r *extendedResponseWriter) Write(b []byte) (int, error) {
 	size, err := r.ResponseWriter.Write(b)
-	r.extendedResponseData.size = size
+	r.extendedResponseData.size += size
 	return size, err
 }
 
@@ -22,3 +22,12 @@ func (r *extendedResponseWriter) WriteHeader(statusCode int) {
 	r.ResponseWriter.WriteHeader(statusCode)
 	r.extendedResponseData.statusCode = statusCode
 }
+
+func (r *extendedResponseWriter) Flush() {
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the features of the original writer.
+func (r *extendedResponseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
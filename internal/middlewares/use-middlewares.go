@@ -16,6 +16,12 @@ func conveyor(h http.HandlerFunc, middlewares ...Middleware) http.HandlerFunc {
 	return h
 }
 
-func UseMiddlewares(handler http.HandlerFunc) http.HandlerFunc {
-	return conveyor(handler, compressor.HandleWithGzipCompressor, logger.HandleWithLogging)
+// UseMiddlewares wraps the handler with the common middlewares. Extra middlewares run
+// after logging and before compression, in the reverse order they are passed in.
+func UseMiddlewares(handler http.HandlerFunc, extra ...Middleware) http.HandlerFunc {
+	middlewares := []Middleware{compressor.HandleWithGzipCompressor}
+	middlewares = append(middlewares, extra...)
+	middlewares = append(middlewares, logger.HandleWithLogging)
+
+	return conveyor(handler, middlewares...)
 }
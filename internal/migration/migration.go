@@ -0,0 +1,130 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+	"github.com/with0p/golang-url-shortener.git/internal/storage"
+)
+
+const DefaultBatchSize = 500
+
+// Report tells how the links were copied. Links already in the destination are skipped,
+// the keys taken there by a different link are conflicts.
+type Report struct {
+	Read      int
+	Written   int
+	Skipped   int
+	Conflicts []string
+	Failures  []Failure
+	// Verified counts the links of the source found in the destination afterwards,
+	// Missing lists the others.
+	Verified int
+	Missing  []string
+}
+
+type Failure struct {
+	ShortURLKey string
+	Err         error
+}
+
+func (report Report) OK() bool {
+	return len(report.Conflicts) == 0 && len(report.Failures) == 0 && len(report.Missing) == 0
+}
+
+func (report Report) String() string {
+	return fmt.Sprintf("read %d, written %d, already there %d, conflicts %d, failed %d, verified %d of %d",
+		report.Read, report.Written, report.Skipped, len(report.Conflicts), len(report.Failures), report.Verified, report.Read)
+}
+
+// Migrate copies the links from one storage to another batchSize links at a time in
+// creation order, then checks that every link made it. Links are copied whole, with
+// their options, variants and click counters. Their edit history, the API keys, the
+// quota usage and the secret signing the user cookies are not copied.
+func Migrate(ctx context.Context, from storage.Storage, to storage.Storage, batchSize int) (Report, error) {
+	var report Report
+
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	err := forEachBatch(ctx, from, batchSize, func(records []commontypes.URLRecord) {
+		report.Read += len(records)
+		copyBatch(ctx, to, records, &report)
+	})
+	if err != nil {
+		return report, err
+	}
+
+	err = forEachBatch(ctx, from, batchSize, func(records []commontypes.URLRecord) {
+		for _, record := range records {
+			copied, err := to.Read(ctx, record.ShortURLKey)
+			if err == nil && copied.FullURL == record.FullURL {
+				report.Verified++
+				continue
+			}
+			report.Missing = append(report.Missing, record.ShortURLKey)
+		}
+	})
+
+	return report, err
+}
+
+// copyBatch writes the links in one go. When that fails, e.g. because some of them are
+// in the destination already, they are copied one by one to tell which.
+func copyBatch(ctx context.Context, to storage.Storage, records []commontypes.URLRecord, report *Report) {
+	if err := to.WriteBatch(ctx, records); err == nil {
+		report.Written += len(records)
+		return
+	}
+
+	for _, record := range records {
+		copyRecord(ctx, to, record, report)
+	}
+}
+
+func copyRecord(ctx context.Context, to storage.Storage, record commontypes.URLRecord, report *Report) {
+	err := to.Write(ctx, record)
+	if err == nil {
+		report.Written++
+		return
+	}
+
+	if !errors.Is(err, customerrors.ErrUniqueKeyConstrantViolation) {
+		report.Failures = append(report.Failures, Failure{ShortURLKey: record.ShortURLKey, Err: err})
+		return
+	}
+
+	// A key taken by the same link was copied by an earlier run.
+	existing, err := to.Read(ctx, record.ShortURLKey)
+	if err == nil && existing.FullURL == record.FullURL && existing.UserID == record.UserID {
+		report.Skipped++
+		return
+	}
+	report.Conflicts = append(report.Conflicts, record.ShortURLKey)
+}
+
+func forEachBatch(ctx context.Context, from storage.Storage, batchSize int, apply func(records []commontypes.URLRecord)) error {
+	var after *commontypes.ListCursor
+
+	for {
+		records, err := from.List(ctx, after, batchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(records) > 0 {
+			apply(records)
+		}
+
+		if len(records) < batchSize {
+			return nil
+		}
+
+		cursor := records[len(records)-1].ListCursor()
+		after = &cursor
+	}
+}
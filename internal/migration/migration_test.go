@@ -0,0 +1,69 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	"github.com/with0p/golang-url-shortener.git/internal/storage"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	source := storage.URLStorageMap{}
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("key%d", i)
+		source[key] = commontypes.URLRecord{
+			ShortURLKey: key,
+			FullURL:     fmt.Sprintf("https://practicum.yandex.kz/%d", i),
+			UserID:      "user",
+			CreatedAt:   createdAt.Add(time.Duration(i) * time.Minute),
+			LinkOptions: commontypes.LinkOptions{Title: key},
+		}
+	}
+	variants := []commontypes.Variant{{ID: "a", URL: "https://practicum.yandex.kz/a", Weight: 100}}
+	withVariants := source["key4"]
+	withVariants.Variants = variants
+	withVariants.Clicks = 3
+	withVariants.VariantClicks = map[string]int64{"a": 3}
+	withVariants.CountryClicks = map[string]int64{"KZ": 2}
+	source["key4"] = withVariants
+
+	destination := storage.NewInMemoryStorage(storage.URLStorageMap{
+		"key1": source["key1"],
+		"key2": {ShortURLKey: "key2", FullURL: "https://practicum.yandex.kz/other", CreatedAt: createdAt},
+	})
+
+	report, err := Migrate(ctx, storage.NewInMemoryStorage(source), destination, 2)
+	require.Nil(t, err)
+
+	assert.Equal(t, 5, report.Read)
+	assert.Equal(t, 3, report.Written)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, []string{"key2"}, report.Conflicts)
+	assert.Empty(t, report.Failures)
+	assert.Equal(t, 4, report.Verified)
+	assert.Equal(t, []string{"key2"}, report.Missing)
+	assert.False(t, report.OK())
+
+	copied, err := destination.Read(ctx, "key4")
+	require.Nil(t, err)
+	assert.Equal(t, source["key4"].FullURL, copied.FullURL)
+	assert.Equal(t, "key4", copied.Title)
+	assert.Equal(t, source["key4"].CreatedAt, copied.CreatedAt)
+	assert.Equal(t, variants, copied.Variants)
+	assert.Equal(t, int64(3), copied.Clicks)
+	assert.Equal(t, source["key4"].VariantClicks, copied.VariantClicks)
+	assert.Equal(t, source["key4"].CountryClicks, copied.CountryClicks)
+
+	report, err = Migrate(ctx, storage.NewInMemoryStorage(storage.URLStorageMap{"key0": source["key0"]}), destination, 0)
+	require.Nil(t, err)
+	assert.Equal(t, Report{Read: 1, Skipped: 1, Verified: 1}, report)
+	assert.True(t, report.OK())
+}
@@ -35,34 +35,213 @@ func (m *MockService) EXPECT() *MockServiceMockRecorder {
 	return m.recorder
 }
 
+// AuthenticateAPIKey mocks base method.
+func (m *MockService) AuthenticateAPIKey(arg0 context.Context, arg1 string) (commontypes.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(commontypes.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthenticateAPIKey indicates an expected call of AuthenticateAPIKey.
+func (mr *MockServiceMockRecorder) AuthenticateAPIKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateAPIKey", reflect.TypeOf((*MockService)(nil).AuthenticateAPIKey), arg0, arg1)
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockService) CreateAPIKey(arg0 context.Context, arg1 string, arg2 []string) (commontypes.APIKey, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(commontypes.APIKey)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockServiceMockRecorder) CreateAPIKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockService)(nil).CreateAPIKey), arg0, arg1, arg2)
+}
+
+// DeleteURL mocks base method.
+func (m *MockService) DeleteURL(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteURL", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteURL indicates an expected call of DeleteURL.
+func (mr *MockServiceMockRecorder) DeleteURL(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteURL", reflect.TypeOf((*MockService)(nil).DeleteURL), arg0, arg1)
+}
+
+// ForceDeleteURL mocks base method.
+func (m *MockService) ForceDeleteURL(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForceDeleteURL", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForceDeleteURL indicates an expected call of ForceDeleteURL.
+func (mr *MockServiceMockRecorder) ForceDeleteURL(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForceDeleteURL", reflect.TypeOf((*MockService)(nil).ForceDeleteURL), arg0, arg1)
+}
+
+// GetOwnedURLRecord mocks base method.
+func (m *MockService) GetOwnedURLRecord(arg0 context.Context, arg1 string) (commontypes.URLRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOwnedURLRecord", arg0, arg1)
+	ret0, _ := ret[0].(commontypes.URLRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOwnedURLRecord indicates an expected call of GetOwnedURLRecord.
+func (mr *MockServiceMockRecorder) GetOwnedURLRecord(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOwnedURLRecord", reflect.TypeOf((*MockService)(nil).GetOwnedURLRecord), arg0, arg1)
+}
+
+// GetQuota mocks base method.
+func (m *MockService) GetQuota(arg0 context.Context) (commontypes.QuotaReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetQuota", arg0)
+	ret0, _ := ret[0].(commontypes.QuotaReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQuota indicates an expected call of GetQuota.
+func (mr *MockServiceMockRecorder) GetQuota(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuota", reflect.TypeOf((*MockService)(nil).GetQuota), arg0)
+}
+
+// GetStats mocks base method.
+func (m *MockService) GetStats(arg0 context.Context) (commontypes.Stats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStats", arg0)
+	ret0, _ := ret[0].(commontypes.Stats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStats indicates an expected call of GetStats.
+func (mr *MockServiceMockRecorder) GetStats(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockService)(nil).GetStats), arg0)
+}
+
 // GetTrueURL mocks base method.
-func (m *MockService) GetTrueURL(arg0 context.Context, arg1 string) (string, error) {
+func (m *MockService) GetTrueURL(arg0 context.Context, arg1 string, arg2 commontypes.Visit) (commontypes.Redirect, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTrueURL", arg0, arg1)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "GetTrueURL", arg0, arg1, arg2)
+	ret0, _ := ret[0].(commontypes.Redirect)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetTrueURL indicates an expected call of GetTrueURL.
-func (mr *MockServiceMockRecorder) GetTrueURL(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) GetTrueURL(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrueURL", reflect.TypeOf((*MockService)(nil).GetTrueURL), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrueURL", reflect.TypeOf((*MockService)(nil).GetTrueURL), arg0, arg1, arg2)
+}
+
+// GetURLHistory mocks base method.
+func (m *MockService) GetURLHistory(arg0 context.Context, arg1 string) ([]commontypes.HistoryEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetURLHistory", arg0, arg1)
+	ret0, _ := ret[0].([]commontypes.HistoryEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetURLHistory indicates an expected call of GetURLHistory.
+func (mr *MockServiceMockRecorder) GetURLHistory(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLHistory", reflect.TypeOf((*MockService)(nil).GetURLHistory), arg0, arg1)
+}
+
+// GetURLRecord mocks base method.
+func (m *MockService) GetURLRecord(arg0 context.Context, arg1 string) (commontypes.URLRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetURLRecord", arg0, arg1)
+	ret0, _ := ret[0].(commontypes.URLRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetURLRecord indicates an expected call of GetURLRecord.
+func (mr *MockServiceMockRecorder) GetURLRecord(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLRecord", reflect.TypeOf((*MockService)(nil).GetURLRecord), arg0, arg1)
+}
+
+// ListAPIKeys mocks base method.
+func (m *MockService) ListAPIKeys(arg0 context.Context) ([]commontypes.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAPIKeys", arg0)
+	ret0, _ := ret[0].([]commontypes.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAPIKeys indicates an expected call of ListAPIKeys.
+func (mr *MockServiceMockRecorder) ListAPIKeys(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAPIKeys", reflect.TypeOf((*MockService)(nil).ListAPIKeys), arg0)
+}
+
+// ListURLs mocks base method.
+func (m *MockService) ListURLs(arg0 context.Context, arg1 *commontypes.ListCursor, arg2 int) (commontypes.URLPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListURLs", arg0, arg1, arg2)
+	ret0, _ := ret[0].(commontypes.URLPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListURLs indicates an expected call of ListURLs.
+func (mr *MockServiceMockRecorder) ListURLs(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListURLs", reflect.TypeOf((*MockService)(nil).ListURLs), arg0, arg1, arg2)
+}
+
+// ListUserURLs mocks base method.
+func (m *MockService) ListUserURLs(arg0 context.Context, arg1 commontypes.ListQuery) (commontypes.URLPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUserURLs", arg0, arg1)
+	ret0, _ := ret[0].(commontypes.URLPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUserURLs indicates an expected call of ListUserURLs.
+func (mr *MockServiceMockRecorder) ListUserURLs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUserURLs", reflect.TypeOf((*MockService)(nil).ListUserURLs), arg0, arg1)
 }
 
 // MakeShortURL mocks base method.
-func (m *MockService) MakeShortURL(arg0 context.Context, arg1 string) (string, error) {
+func (m *MockService) MakeShortURL(arg0 context.Context, arg1 string, arg2 commontypes.LinkOptions) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MakeShortURL", arg0, arg1)
+	ret := m.ctrl.Call(m, "MakeShortURL", arg0, arg1, arg2)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // MakeShortURL indicates an expected call of MakeShortURL.
-func (mr *MockServiceMockRecorder) MakeShortURL(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) MakeShortURL(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeShortURL", reflect.TypeOf((*MockService)(nil).MakeShortURL), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeShortURL", reflect.TypeOf((*MockService)(nil).MakeShortURL), arg0, arg1, arg2)
 }
 
 // MakeShortURLBatch mocks base method.
@@ -79,3 +258,47 @@ func (mr *MockServiceMockRecorder) MakeShortURLBatch(arg0, arg1 interface{}) *go
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeShortURLBatch", reflect.TypeOf((*MockService)(nil).MakeShortURLBatch), arg0, arg1)
 }
+
+// RevokeAPIKey mocks base method.
+func (m *MockService) RevokeAPIKey(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockServiceMockRecorder) RevokeAPIKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockService)(nil).RevokeAPIKey), arg0, arg1)
+}
+
+// SetVariants mocks base method.
+func (m *MockService) SetVariants(arg0 context.Context, arg1 string, arg2 []commontypes.Variant) ([]commontypes.Variant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetVariants", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]commontypes.Variant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetVariants indicates an expected call of SetVariants.
+func (mr *MockServiceMockRecorder) SetVariants(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetVariants", reflect.TypeOf((*MockService)(nil).SetVariants), arg0, arg1, arg2)
+}
+
+// UpdateURL mocks base method.
+func (m *MockService) UpdateURL(arg0 context.Context, arg1 string, arg2 string) (commontypes.URLRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateURL", arg0, arg1, arg2)
+	ret0, _ := ret[0].(commontypes.URLRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateURL indicates an expected call of UpdateURL.
+func (mr *MockServiceMockRecorder) UpdateURL(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateURL", reflect.TypeOf((*MockService)(nil).UpdateURL), arg0, arg1, arg2)
+}
@@ -0,0 +1,78 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/boombuler/barcode/qr"
+)
+
+type Level int
+
+const (
+	Low Level = iota
+	Medium
+	Quartile
+	High
+)
+
+var levels = map[Level]qr.ErrorCorrectionLevel{
+	Low:      qr.L,
+	Medium:   qr.M,
+	Quartile: qr.Q,
+	High:     qr.H,
+}
+
+var ErrDataTooLong = errors.New("data too long for a QR code")
+
+func ParseLevel(str string) (Level, error) {
+	switch strings.ToUpper(str) {
+	case "L":
+		return Low, nil
+	case "", "M":
+		return Medium, nil
+	case "Q":
+		return Quartile, nil
+	case "H":
+		return High, nil
+	default:
+		return Medium, errors.New("unknown error correction level")
+	}
+}
+
+// QRCode holds the modules of a code, the rendering is left to Image, PNG and SVG.
+type QRCode struct {
+	size    int
+	modules [][]bool
+}
+
+// Encode makes the smallest QR code holding the text in byte mode.
+func Encode(text string, level Level) (*QRCode, error) {
+	code, err := qr.Encode(text, levels[level], qr.Unicode)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDataTooLong, err)
+	}
+
+	// The code comes as an image with one pixel per module and no quiet zone.
+	size := code.Bounds().Dx()
+	qrCode := &QRCode{size: size, modules: make([][]bool, size)}
+	for y := range qrCode.modules {
+		qrCode.modules[y] = make([]bool, size)
+		for x := range qrCode.modules[y] {
+			r, _, _, _ := code.At(x, y).RGBA()
+			qrCode.modules[y][x] = r < 0x8000
+		}
+	}
+
+	return qrCode, nil
+}
+
+func (qr *QRCode) Size() int {
+	return qr.size
+}
+
+// Module reports whether the module at column x and row y is dark.
+func (qr *QRCode) Module(x int, y int) bool {
+	return x >= 0 && x < qr.size && y >= 0 && y < qr.size && qr.modules[y][x]
+}
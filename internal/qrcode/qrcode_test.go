@@ -0,0 +1,62 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/makiuchi-d/gozxing"
+	zxingqr "github.com/makiuchi-d/gozxing/qrcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, data []byte) string {
+	t.Helper()
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.Nil(t, err)
+
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	require.Nil(t, err)
+
+	result, err := zxingqr.NewQRCodeReader().Decode(bitmap, nil)
+	require.Nil(t, err)
+
+	return result.GetText()
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		level Level
+	}{
+		{name: "short link", text: "http://localhost:8080/a0c7ecc8", level: Medium},
+		{name: "low", text: "http://localhost:8080/a0c7ecc8", level: Low},
+		{name: "high", text: "https://practicum.yandex.kz/?utm_source=qr&utm_medium=print", level: High},
+		{name: "unicode", text: "https://пример.рф/путь", level: Quartile},
+		{name: "long", text: "https://practicum.yandex.kz/" + strings.Repeat("a", 1000), level: Medium},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qr, err := Encode(tt.text, tt.level)
+			require.Nil(t, err)
+
+			// Finder pattern corners.
+			assert.True(t, qr.Module(0, 0))
+			assert.True(t, qr.Module(qr.Size()-1, 0))
+			assert.True(t, qr.Module(0, qr.Size()-1))
+			assert.False(t, qr.Module(7, 7))
+
+			data, err := qr.PNG(4, 4)
+			require.Nil(t, err)
+			assert.Equal(t, tt.text, decode(t, data))
+		})
+	}
+
+	_, err := Encode(string(make([]byte, 3000)), Low)
+	assert.ErrorIs(t, err, ErrDataTooLong)
+}
@@ -0,0 +1,60 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// Image renders the code with scale pixels per module and a quiet zone of margin
+// modules around it.
+func (qr *QRCode) Image(scale int, margin int) image.Image {
+	scale = max(scale, 1)
+	margin = max(margin, 0)
+
+	side := (qr.size + 2*margin) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+
+	for y := 0; y < side; y++ {
+		for x := 0; x < side; x++ {
+			if qr.Module(x/scale-margin, y/scale-margin) {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+
+	return img
+}
+
+func (qr *QRCode) PNG(scale int, margin int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, qr.Image(scale, margin)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders the code as a square of the given size in pixels.
+func (qr *QRCode) SVG(size int, margin int) string {
+	margin = max(margin, 0)
+	side := qr.size + 2*margin
+
+	var path strings.Builder
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if qr.modules[y][x] {
+				fmt.Fprintf(&path, "M%d,%dh1v1h-1z", x+margin, y+margin)
+			}
+		}
+	}
+
+	return fmt.Sprintf(
+		`<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+			`<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+			`<rect width="100%%" height="100%%" fill="#FFFFFF"/><path d="%s" fill="#000000"/></svg>`+"\n",
+		size, size, side, side, path.String(),
+	)
+}
@@ -0,0 +1,136 @@
+package ratelimiter
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const cleanupInterval = time.Minute
+
+type KeyFunc func(r *http.Request) string
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type RateLimiter struct {
+	mu              sync.Mutex
+	buckets         map[string]*bucket
+	refillPerSecond float64
+	burst           float64
+	keyFunc         KeyFunc
+	lastCleanup     time.Time
+	now             func() time.Time
+}
+
+// NewRateLimiter returns a token bucket limiter that allows burst requests at once
+// and refills at requestsPerMinute. A non-positive burst defaults to requestsPerMinute.
+func NewRateLimiter(requestsPerMinute int, burst int, keyFunc KeyFunc) *RateLimiter {
+	if burst <= 0 {
+		burst = requestsPerMinute
+	}
+
+	return &RateLimiter{
+		buckets:         map[string]*bucket{},
+		refillPerSecond: float64(requestsPerMinute) / 60,
+		burst:           float64(burst),
+		keyFunc:         keyFunc,
+		lastCleanup:     time.Now(),
+		now:             time.Now,
+	}
+}
+
+func (limiter *RateLimiter) HandleWithRateLimit(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allowed, remaining, reset := limiter.take(limiter.keyFunc(r))
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(int(limiter.burst)))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(reset)))
+
+		if !allowed {
+			retryAfter := time.Duration(float64(time.Second) / limiter.refillPerSecond)
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	}
+}
+
+// Check reports whether the key has a token left without taking it, and if not,
+// how long until it has.
+func (limiter *RateLimiter) Check(key string) (bool, time.Duration) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	b := limiter.refill(key)
+	if b.tokens >= 1 {
+		return true, 0
+	}
+
+	return false, time.Duration((1 - b.tokens) / limiter.refillPerSecond * float64(time.Second))
+}
+
+// Take takes a token of the key, it reports false when there was none.
+func (limiter *RateLimiter) Take(key string) bool {
+	allowed, _, _ := limiter.take(key)
+	return allowed
+}
+
+func (limiter *RateLimiter) take(key string) (bool, int, time.Duration) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	b := limiter.refill(key)
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	reset := time.Duration((limiter.burst - b.tokens) / limiter.refillPerSecond * float64(time.Second))
+
+	return allowed, int(b.tokens), reset
+}
+
+func (limiter *RateLimiter) refill(key string) *bucket {
+	now := limiter.now()
+	limiter.cleanup(now)
+
+	b, ok := limiter.buckets[key]
+	if !ok {
+		b = &bucket{tokens: limiter.burst, updatedAt: now}
+		limiter.buckets[key] = b
+	}
+
+	b.tokens = math.Min(limiter.burst, b.tokens+now.Sub(b.updatedAt).Seconds()*limiter.refillPerSecond)
+	b.updatedAt = now
+
+	return b
+}
+
+// cleanup drops the buckets that have been idle long enough to refill completely,
+// they are indistinguishable from new ones.
+func (limiter *RateLimiter) cleanup(now time.Time) {
+	if now.Sub(limiter.lastCleanup) < cleanupInterval {
+		return
+	}
+	limiter.lastCleanup = now
+
+	fullAfter := time.Duration(limiter.burst / limiter.refillPerSecond * float64(time.Second))
+	for key, b := range limiter.buckets {
+		if now.Sub(b.updatedAt) > fullAfter {
+			delete(limiter.buckets, key)
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
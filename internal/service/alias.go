@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/with0p/golang-url-shortener.git/internal/auth"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+)
+
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,64}$`)
+
+// reservedAliases are the paths of the service shadowing the short URLs.
+var reservedAliases = map[string]bool{
+	"api":  true,
+	"ping": true,
+}
+
+func validateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return errors.New("alias must be 3 to 64 letters, digits, \"-\" or \"_\"")
+	}
+
+	if reservedAliases[alias] {
+		return fmt.Errorf("alias %q is reserved", alias)
+	}
+
+	return nil
+}
+
+// checkAliasFree tells ErrAliasTaken when another link has the alias, and
+// ErrUniqueKeyConstrantViolation when it is the link of the user to the same URL, as
+// shortening an URL twice does. It only saves work, Write reports the keys taken since.
+func (s *ShortURLService) checkAliasFree(ctx context.Context, alias string, trueURL string) error {
+	existing, err := s.storage.Read(ctx, alias)
+	if err != nil {
+		return nil
+	}
+
+	if existing.FullURL == trueURL && existing.UserID == auth.UserIDFromContext(ctx) {
+		return customerrors.ErrUniqueKeyConstrantViolation
+	}
+	return fmt.Errorf("%w: %s", customerrors.ErrAliasTaken, alias)
+}
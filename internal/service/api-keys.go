@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/with0p/golang-url-shortener.git/internal/auth"
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+)
+
+// API keys look like "usk_<id>_<secret>", the id is public and names the key in listings.
+const apiKeyPrefix = "usk_"
+
+const maxAPIKeyNameLength = 100
+
+// CreateAPIKey issues a key for the user of the context, the key itself is returned
+// once and only its hash is stored.
+func (s *ShortURLService) CreateAPIKey(ctx context.Context, name string, scopes []string) (commontypes.APIKey, string, error) {
+	userID, err := cookieUser(ctx)
+	if err != nil {
+		return commontypes.APIKey{}, "", err
+	}
+
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxAPIKeyNameLength {
+		return commontypes.APIKey{}, "", fmt.Errorf("name must be no longer than %d characters", maxAPIKeyNameLength)
+	}
+
+	if len(scopes) == 0 {
+		return commontypes.APIKey{}, "", errors.New("at least one scope is required")
+	}
+
+	var keyScopes []string
+	for _, scope := range scopes {
+		if !slices.Contains(auth.Scopes, scope) {
+			return commontypes.APIKey{}, "", fmt.Errorf("scope must be one of %s", strings.Join(auth.Scopes, ", "))
+		}
+		if !slices.Contains(keyScopes, scope) {
+			keyScopes = append(keyScopes, scope)
+		}
+	}
+
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return commontypes.APIKey{}, "", errors.New("could not generate API key")
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return commontypes.APIKey{}, "", errors.New("could not generate API key")
+	}
+
+	apiKey := commontypes.APIKey{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		Name:      name,
+		Scopes:    keyScopes,
+		CreatedAt: time.Now(),
+	}
+	key := apiKeyPrefix + apiKey.ID + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	apiKey.Hash = hashAPIKey(key)
+
+	if err := s.storage.WriteAPIKey(ctx, apiKey); err != nil {
+		logger.LogError(err)
+		return commontypes.APIKey{}, "", errors.New("could not store API key")
+	}
+
+	return apiKey, key, nil
+}
+
+func (s *ShortURLService) ListAPIKeys(ctx context.Context) ([]commontypes.APIKey, error) {
+	userID, err := cookieUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.storage.ListAPIKeys(ctx, userID)
+}
+
+func (s *ShortURLService) RevokeAPIKey(ctx context.Context, id string) error {
+	userID, err := cookieUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.storage.RevokeAPIKey(ctx, userID, id, time.Now())
+	if errors.Is(err, customerrors.ErrNotFound) {
+		return fmt.Errorf("%w: %s", customerrors.ErrNotFound, id)
+	}
+	if err != nil {
+		logger.LogError(err)
+		return errors.New("could not revoke API key")
+	}
+	return nil
+}
+
+// AuthenticateAPIKey returns the key unless it is unknown or revoked.
+func (s *ShortURLService) AuthenticateAPIKey(ctx context.Context, key string) (commontypes.APIKey, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return commontypes.APIKey{}, customerrors.ErrUnauthorized
+	}
+
+	apiKey, err := s.storage.ReadAPIKey(ctx, hashAPIKey(key))
+	if err != nil {
+		if !errors.Is(err, customerrors.ErrNotFound) {
+			logger.LogError(err)
+		}
+		return commontypes.APIKey{}, customerrors.ErrUnauthorized
+	}
+
+	if !apiKey.RevokedAt.IsZero() {
+		return commontypes.APIKey{}, customerrors.ErrUnauthorized
+	}
+
+	return apiKey, nil
+}
+
+// Keys are random enough for a plain hash, unlike passwords they need no slow one.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// cookieUser returns the user of the context, API keys cannot manage API keys.
+func cookieUser(ctx context.Context) (string, error) {
+	identity, ok := auth.IdentityFromContext(ctx)
+	if !ok {
+		return "", customerrors.ErrUnauthorized
+	}
+	if identity.APIKeyID != "" {
+		return "", customerrors.ErrForbidden
+	}
+	return identity.UserID, nil
+}
+
+// editor names who changes a link in its history, with the API key used if any.
+func editor(ctx context.Context) string {
+	identity, _ := auth.IdentityFromContext(ctx)
+	if identity.APIKeyID != "" {
+		return identity.UserID + " (API key " + identity.APIKeyID + ")"
+	}
+	return identity.UserID
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	"github.com/with0p/golang-url-shortener.git/internal/useragent"
+)
+
+const maxDeviceRules = 20
+
+func (s *ShortURLService) validateDeviceRules(rules []commontypes.DeviceRule) error {
+	if len(rules) > maxDeviceRules {
+		return fmt.Errorf("no more than %d device rules allowed", maxDeviceRules)
+	}
+
+	for _, rule := range rules {
+		if rule.OS == "" && rule.Device == "" && rule.Bot == nil {
+			return errors.New("device rule must have os, device or bot")
+		}
+
+		if rule.OS != "" && !slices.Contains(useragent.OSes, rule.OS) {
+			return fmt.Errorf("unknown os %q in device rule", rule.OS)
+		}
+
+		if rule.Device != "" && !slices.Contains(useragent.Devices, rule.Device) {
+			return fmt.Errorf("unknown device %q in device rule", rule.Device)
+		}
+
+		parsedURL, err := url.ParseRequestURI(rule.URL)
+		if err != nil {
+			return errors.New("device rule URL is not a URL")
+		}
+
+		if err := s.urlPolicy.Check(rule.URL, parsedURL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// routeByDevice returns the URL of the first rule matching the visitor.
+func routeByDevice(rules []commontypes.DeviceRule, userAgentHeader string) (string, bool) {
+	if len(rules) == 0 {
+		return "", false
+	}
+
+	ua := useragent.Parse(userAgentHeader)
+
+	for _, rule := range rules {
+		if rule.OS != "" && rule.OS != ua.OS {
+			continue
+		}
+		if rule.Device != "" && rule.Device != ua.Device {
+			continue
+		}
+		if rule.Bot != nil && *rule.Bot != ua.Bot {
+			continue
+		}
+		return rule.URL, true
+	}
+
+	return "", false
+}
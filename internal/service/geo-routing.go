@@ -0,0 +1,108 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	"github.com/with0p/golang-url-shortener.git/internal/geoip"
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+)
+
+const (
+	maxGeoRules         = 20
+	maxGeoRuleCountries = 50
+)
+
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// prepareGeoRules validates the rules and uppercases their country codes.
+func (s *ShortURLService) prepareGeoRules(rules []commontypes.GeoRule) ([]commontypes.GeoRule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	if s.geoIP == nil {
+		return nil, errors.New("geo routing is not configured")
+	}
+
+	if len(rules) > maxGeoRules {
+		return nil, fmt.Errorf("no more than %d geo rules allowed", maxGeoRules)
+	}
+
+	prepared := make([]commontypes.GeoRule, len(rules))
+
+	for i, rule := range rules {
+		if len(rule.Countries) == 0 || len(rule.Countries) > maxGeoRuleCountries {
+			return nil, fmt.Errorf("geo rule must have from 1 to %d countries", maxGeoRuleCountries)
+		}
+
+		countries := make([]string, len(rule.Countries))
+		for j, country := range rule.Countries {
+			countries[j] = strings.ToUpper(country)
+			if !countryCodePattern.MatchString(countries[j]) {
+				return nil, fmt.Errorf("%q is not a two letter country code", country)
+			}
+		}
+
+		parsedURL, err := url.ParseRequestURI(rule.URL)
+		if err != nil {
+			return nil, errors.New("geo rule URL is not a URL")
+		}
+
+		if err := s.urlPolicy.Check(rule.URL, parsedURL); err != nil {
+			return nil, err
+		}
+
+		prepared[i] = commontypes.GeoRule{Countries: countries, URL: rule.URL}
+	}
+
+	return prepared, nil
+}
+
+// country returns the country of the visitor or an empty string when it is unknown
+// or no GeoIP database is configured.
+func (s *ShortURLService) country(ip net.IP) string {
+	if s.geoIP == nil || ip == nil {
+		return ""
+	}
+
+	country, err := s.geoIP.Country(ip)
+	if err != nil {
+		logger.LogError(err)
+	}
+	return country
+}
+
+// routeByCountry returns the URL of the first rule listing the country.
+func routeByCountry(rules []commontypes.GeoRule, country string) (string, bool) {
+	if country == "" {
+		return "", false
+	}
+
+	for _, rule := range rules {
+		if slices.Contains(rule.Countries, country) {
+			return rule.URL, true
+		}
+	}
+
+	return "", false
+}
+
+func openGeoIP(path string) *geoip.Reader {
+	if path == "" {
+		return nil
+	}
+
+	reader, err := geoip.Open(path)
+	if err != nil {
+		logger.LogError(fmt.Errorf("could not open GeoIP database, geo routing is disabled: %w", err))
+		return nil
+	}
+	return reader
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+)
+
+func TestRouteByCountry(t *testing.T) {
+	rules := []commontypes.GeoRule{
+		{Countries: []string{"DE", "AT", "CH"}, URL: "https://example.com/de"},
+		{Countries: []string{"KZ"}, URL: "https://example.com/kz"},
+		{Countries: []string{"AT"}, URL: "https://example.com/at"},
+	}
+
+	tests := []struct {
+		name        string
+		country     string
+		expectedURL string
+		routed      bool
+	}{
+		{
+			name:        "Check country of the first rule",
+			country:     "CH",
+			expectedURL: "https://example.com/de",
+			routed:      true,
+		},
+		{
+			name:        "Check first matching rule wins",
+			country:     "AT",
+			expectedURL: "https://example.com/de",
+			routed:      true,
+		},
+		{
+			name:        "Check country of another rule",
+			country:     "KZ",
+			expectedURL: "https://example.com/kz",
+			routed:      true,
+		},
+		{
+			name:    "Check country without rule",
+			country: "US",
+		},
+		{
+			name:    "Check unknown country",
+			country: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, routed := routeByCountry(rules, tt.country)
+			assert.Equal(t, tt.routed, routed)
+			assert.Equal(t, tt.expectedURL, url)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+)
+
+const (
+	maxTitleLength = 200
+	maxNotesLength = 2000
+	maxTags        = 20
+	maxTagLength   = 50
+
+	defaultListLimit = 50
+	maxListLimit     = 100
+)
+
+var listSorts = []string{
+	commontypes.SortCreatedAsc,
+	commontypes.SortCreatedDesc,
+	commontypes.SortClicksAsc,
+	commontypes.SortClicksDesc,
+	commontypes.SortTitleAsc,
+	commontypes.SortTitleDesc,
+}
+
+// prepareMetadata validates the title, notes and tags of the link, tags are trimmed,
+// lowercased and deduplicated.
+func prepareMetadata(options *commontypes.LinkOptions) error {
+	options.Title = strings.TrimSpace(options.Title)
+	if utf8.RuneCountInString(options.Title) > maxTitleLength {
+		return fmt.Errorf("title must be no longer than %d characters", maxTitleLength)
+	}
+
+	if utf8.RuneCountInString(options.Notes) > maxNotesLength {
+		return fmt.Errorf("notes must be no longer than %d characters", maxNotesLength)
+	}
+
+	tags, err := normalizeTags(options.Tags)
+	if err != nil {
+		return err
+	}
+	options.Tags = tags
+
+	return nil
+}
+
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) > maxTags {
+		return nil, fmt.Errorf("no more than %d tags allowed", maxTags)
+	}
+
+	var normalized []string
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" || utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, fmt.Errorf("tags must be from 1 to %d characters", maxTagLength)
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+
+	return normalized, nil
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+func normalizeListQuery(query commontypes.ListQuery) (commontypes.ListQuery, error) {
+	if query.Sort == "" {
+		query.Sort = commontypes.SortCreatedDesc
+	}
+
+	if !slices.Contains(listSorts, query.Sort) {
+		return query, fmt.Errorf("sort must be one of %s", strings.Join(listSorts, ", "))
+	}
+
+	switch {
+	case query.Limit <= 0:
+		query.Limit = defaultListLimit
+	case query.Limit > maxListLimit:
+		query.Limit = maxListLimit
+	}
+
+	query.Tag = normalizeTag(query.Tag)
+	query.Search = strings.TrimSpace(query.Search)
+
+	return query, nil
+}
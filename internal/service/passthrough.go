@@ -0,0 +1,71 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+)
+
+const (
+	QueryPrecedenceLink    = "link"
+	QueryPrecedenceRequest = "request"
+)
+
+func isValidQueryPrecedence(precedence string) bool {
+	return precedence == QueryPrecedenceLink || precedence == QueryPrecedenceRequest
+}
+
+// applyPassthrough carries the query and the trailing path of the visit over to the
+// destination URL when the link opted in.
+func applyPassthrough(destination string, options commontypes.LinkOptions, visit commontypes.Visit, precedence string) (string, error) {
+	passPath := options.PassPath && visit.Path != ""
+	passQuery := options.PassQuery && len(visit.Query) > 0
+
+	if !passPath && !passQuery {
+		return destination, nil
+	}
+
+	destinationURL, err := url.Parse(destination)
+	if err != nil {
+		return "", errors.New("could not parse destination URL")
+	}
+
+	if passPath {
+		extraPath := cleanExtraPath(visit.Path)
+		if extraPath != "" {
+			destinationURL.Path = strings.TrimSuffix(destinationURL.Path, "/") + "/" + extraPath
+			destinationURL.RawPath = ""
+		}
+	}
+
+	if passQuery {
+		if options.QueryPrecedence != "" {
+			precedence = options.QueryPrecedence
+		}
+
+		query := destinationURL.Query()
+		for key, values := range visit.Query {
+			if precedence == QueryPrecedenceRequest || !query.Has(key) {
+				query[key] = values
+			}
+		}
+		destinationURL.RawQuery = query.Encode()
+	}
+
+	return destinationURL.String(), nil
+}
+
+// cleanExtraPath drops empty, "." and ".." segments so that the visitor cannot
+// climb above the destination path.
+func cleanExtraPath(extraPath string) string {
+	var segments []string
+	for _, segment := range strings.Split(extraPath, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+)
+
+func TestApplyPassthrough(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination string
+		options     commontypes.LinkOptions
+		visit       commontypes.Visit
+		precedence  string
+		expectedURL string
+	}{
+		{
+			name:        "Check passthrough is disabled by default",
+			destination: "https://example.com/landing",
+			visit:       commontypes.Visit{Query: url.Values{"utm_source": {"mail"}}, Path: "docs"},
+			precedence:  QueryPrecedenceLink,
+			expectedURL: "https://example.com/landing",
+		},
+		{
+			name:        "Check query is merged",
+			destination: "https://example.com/landing?id=1",
+			options:     commontypes.LinkOptions{PassQuery: true},
+			visit:       commontypes.Visit{Query: url.Values{"utm_source": {"mail"}}},
+			precedence:  QueryPrecedenceLink,
+			expectedURL: "https://example.com/landing?id=1&utm_source=mail",
+		},
+		{
+			name:        "Check link wins on conflict",
+			destination: "https://example.com/landing?id=1",
+			options:     commontypes.LinkOptions{PassQuery: true},
+			visit:       commontypes.Visit{Query: url.Values{"id": {"2"}}},
+			precedence:  QueryPrecedenceLink,
+			expectedURL: "https://example.com/landing?id=1",
+		},
+		{
+			name:        "Check request wins on conflict",
+			destination: "https://example.com/landing?id=1",
+			options:     commontypes.LinkOptions{PassQuery: true},
+			visit:       commontypes.Visit{Query: url.Values{"id": {"2"}}},
+			precedence:  QueryPrecedenceRequest,
+			expectedURL: "https://example.com/landing?id=2",
+		},
+		{
+			name:        "Check link precedence overrides default",
+			destination: "https://example.com/landing?id=1",
+			options:     commontypes.LinkOptions{PassQuery: true, QueryPrecedence: QueryPrecedenceRequest},
+			visit:       commontypes.Visit{Query: url.Values{"id": {"2"}}},
+			precedence:  QueryPrecedenceLink,
+			expectedURL: "https://example.com/landing?id=2",
+		},
+		{
+			name:        "Check path is appended",
+			destination: "https://example.com/docs/",
+			options:     commontypes.LinkOptions{PassPath: true},
+			visit:       commontypes.Visit{Path: "guide/intro"},
+			precedence:  QueryPrecedenceLink,
+			expectedURL: "https://example.com/docs/guide/intro",
+		},
+		{
+			name:        "Check path cannot climb up",
+			destination: "https://example.com/docs",
+			options:     commontypes.LinkOptions{PassPath: true},
+			visit:       commontypes.Visit{Path: "../../admin"},
+			precedence:  QueryPrecedenceLink,
+			expectedURL: "https://example.com/docs/admin",
+		},
+		{
+			name:        "Check path and query together",
+			destination: "https://example.com/docs?lang=en",
+			options:     commontypes.LinkOptions{PassPath: true, PassQuery: true},
+			visit:       commontypes.Visit{Path: "guide", Query: url.Values{"page": {"2"}}},
+			precedence:  QueryPrecedenceLink,
+			expectedURL: "https://example.com/docs/guide?lang=en&page=2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := applyPassthrough(tt.destination, tt.options, tt.visit, tt.precedence)
+			require.Nil(t, err)
+			assert.Equal(t, tt.expectedURL, result)
+		})
+	}
+}
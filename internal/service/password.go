@@ -0,0 +1,53 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const defaultPasswordAttempts = 5
+
+// bcrypt ignores everything after the 72nd byte.
+const maxPasswordLength = 72
+
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+
+	if len(password) > maxPasswordLength {
+		return "", fmt.Errorf("password must be no longer than %d bytes", maxPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", errors.New("could not hash password")
+	}
+
+	return string(hash), nil
+}
+
+// checkPassword compares the password with the hash of the link, only failed
+// attempts count towards the throttling. Attempts are throttled per link and client,
+// so that guessing from one client does not lock out the others.
+func (s *ShortURLService) checkPassword(id string, hash string, visit commontypes.Visit) error {
+	if visit.Password == "" {
+		return customerrors.ErrPasswordRequired
+	}
+
+	key := id + "\n" + visit.ClientIP.String()
+	if allowed, retryAfter := s.passwordLimiter.Check(key); !allowed {
+		return &customerrors.RetryAfterError{Err: customerrors.ErrTooManyAttempts, RetryAfter: retryAfter}
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(visit.Password)) != nil {
+		s.passwordLimiter.Take(key)
+		return customerrors.ErrWrongPassword
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/with0p/golang-url-shortener.git/internal/auth"
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+	"github.com/with0p/golang-url-shortener.git/internal/storage"
+)
+
+const (
+	QuotaLinksPerDay = "links_per_day"
+	QuotaActiveLinks = "active_links"
+	QuotaBatchSize   = "batch_size"
+)
+
+// QuotaLimits are the quotas of a user or of an API key, zero means no limit. API keys
+// have no active links limit of their own, their links count towards their user's.
+type QuotaLimits struct {
+	LinksPerDay int64
+	ActiveLinks int64
+	BatchSize   int64
+}
+
+// linkReservation holds links counted towards the quotas before they are created.
+type linkReservation struct {
+	ctx      context.Context
+	storage  storage.Storage
+	userID   string
+	n        int64
+	active   bool
+	period   string
+	subjects []string
+}
+
+// reserveLinks counts n new links towards the quotas of the user and of the API key of
+// the context. The reservation must be settled with done once the links are stored.
+func (s *ShortURLService) reserveLinks(ctx context.Context, n int64) (*linkReservation, error) {
+	reservation := &linkReservation{ctx: context.WithoutCancel(ctx), storage: s.storage}
+
+	identity, ok := auth.IdentityFromContext(ctx)
+	if !ok || n <= 0 {
+		return reservation, nil
+	}
+
+	now := time.Now()
+	reservation.userID = identity.UserID
+	reservation.n = n
+	reservation.period = quotaPeriod(now)
+
+	// The links are held until they are stored, so that concurrent requests cannot
+	// all take the last active links.
+	if limit := s.userQuota.ActiveLinks; limit > 0 {
+		if err := s.storage.ReserveActiveURLs(ctx, identity.UserID, n, limit, now); err != nil {
+			if !errors.Is(err, customerrors.ErrQuotaExceeded) {
+				logger.LogError(err)
+				return nil, errors.New("could not check quota")
+			}
+			return nil, &customerrors.QuotaError{Quota: QuotaActiveLinks, Limit: limit}
+		}
+		reservation.active = true
+	}
+
+	subjects := []string{userQuotaSubject(identity.UserID)}
+	limits := []int64{s.userQuota.LinksPerDay}
+	if identity.APIKeyID != "" {
+		subjects = append(subjects, apiKeyQuotaSubject(identity.APIKeyID))
+		limits = append(limits, s.apiKeyQuota.LinksPerDay)
+	}
+
+	for i, subject := range subjects {
+		// Usage is only counted towards the quotas which have a limit.
+		if limits[i] <= 0 {
+			continue
+		}
+
+		if _, err := s.storage.AddUsage(ctx, subject, reservation.period, n, limits[i]); err != nil {
+			reservation.done(0)
+
+			if !errors.Is(err, customerrors.ErrQuotaExceeded) {
+				logger.LogError(err)
+				return nil, errors.New("could not check quota")
+			}
+			return nil, &customerrors.QuotaError{
+				Quota:      QuotaLinksPerDay,
+				Limit:      limits[i],
+				RetryAfter: quotaResetsAt(now).Sub(now),
+			}
+		}
+		reservation.subjects = append(reservation.subjects, subject)
+	}
+
+	return reservation, nil
+}
+
+// done settles the reservation once stored of its links were created. The daily usage
+// of the others is given back, and the held active links are let go since the stored
+// ones now count by themselves.
+func (reservation *linkReservation) done(stored int64) {
+	if unused := reservation.n - stored; unused > 0 {
+		for _, subject := range reservation.subjects {
+			if _, err := reservation.storage.AddUsage(reservation.ctx, subject, reservation.period, -unused, 0); err != nil {
+				logger.LogError(err)
+			}
+		}
+	}
+
+	if reservation.active {
+		if err := reservation.storage.ReserveActiveURLs(reservation.ctx, reservation.userID, -reservation.n, 0, time.Now()); err != nil {
+			logger.LogError(err)
+		}
+	}
+}
+
+func (s *ShortURLService) checkBatchSize(ctx context.Context, size int) error {
+	identity, ok := auth.IdentityFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	limits := []int64{s.userQuota.BatchSize}
+	if identity.APIKeyID != "" {
+		limits = append(limits, s.apiKeyQuota.BatchSize)
+	}
+
+	for _, limit := range limits {
+		if limit > 0 && int64(size) > limit {
+			return &customerrors.QuotaError{Quota: QuotaBatchSize, Limit: limit}
+		}
+	}
+	return nil
+}
+
+// GetQuota reports the quotas of the user of the context, and of its API key if it
+// authenticated with one.
+func (s *ShortURLService) GetQuota(ctx context.Context) (commontypes.QuotaReport, error) {
+	identity, ok := auth.IdentityFromContext(ctx)
+	if !ok {
+		return commontypes.QuotaReport{}, customerrors.ErrUnauthorized
+	}
+
+	now := time.Now()
+	period := quotaPeriod(now)
+
+	report := commontypes.QuotaReport{
+		LinksPerDay: commontypes.Quota{Limit: s.userQuota.LinksPerDay},
+		ActiveLinks: commontypes.Quota{Limit: s.userQuota.ActiveLinks},
+		BatchSize:   s.userQuota.BatchSize,
+		ResetsAt:    quotaResetsAt(now),
+	}
+
+	var err error
+	if report.LinksPerDay.Used, err = s.storage.ReadUsage(ctx, userQuotaSubject(identity.UserID), period); err != nil {
+		logger.LogError(err)
+		return commontypes.QuotaReport{}, errors.New("could not read quota")
+	}
+
+	if report.ActiveLinks.Used, err = s.storage.CountActiveURLs(ctx, identity.UserID, now); err != nil {
+		logger.LogError(err)
+		return commontypes.QuotaReport{}, errors.New("could not read quota")
+	}
+
+	if identity.APIKeyID != "" {
+		keyReport := &commontypes.QuotaReport{
+			LinksPerDay: commontypes.Quota{Limit: s.apiKeyQuota.LinksPerDay},
+			BatchSize:   s.apiKeyQuota.BatchSize,
+			ResetsAt:    report.ResetsAt,
+		}
+		if keyReport.LinksPerDay.Used, err = s.storage.ReadUsage(ctx, apiKeyQuotaSubject(identity.APIKeyID), period); err != nil {
+			logger.LogError(err)
+			return commontypes.QuotaReport{}, errors.New("could not read quota")
+		}
+		report.APIKey = keyReport
+	}
+
+	return report, nil
+}
+
+// Daily quotas renew at midnight UTC.
+func quotaPeriod(now time.Time) string {
+	return now.UTC().Format(time.DateOnly)
+}
+
+func quotaResetsAt(now time.Time) time.Time {
+	year, month, day := now.UTC().Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+}
+
+func userQuotaSubject(userID string) string {
+	return "user:" + userID
+}
+
+func apiKeyQuotaSubject(id string) string {
+	return "key:" + id
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+)
+
+func validateSchedule(notBefore time.Time, notAfter time.Time, now time.Time) error {
+	if notAfter.IsZero() {
+		return nil
+	}
+
+	if !notAfter.After(now) {
+		return errors.New("not_after must be in the future")
+	}
+
+	if !notBefore.IsZero() && !notAfter.After(notBefore) {
+		return errors.New("not_after must be later than not_before")
+	}
+
+	return nil
+}
+
+// checkSchedule returns the coming soon redirect for links not active yet, nil for
+// active links.
+func (s *ShortURLService) checkSchedule(record commontypes.URLRecord, now time.Time) (*commontypes.Redirect, error) {
+	if !record.NotAfter.IsZero() && !now.Before(record.NotAfter) {
+		return nil, customerrors.ErrLinkExpired
+	}
+
+	if !record.NotBefore.IsZero() && now.Before(record.NotBefore) {
+		if s.comingSoonURL == "" {
+			return nil, customerrors.ErrLinkNotActive
+		}
+		return &commontypes.Redirect{
+			URL:        s.comingSoonURL,
+			StatusCode: http.StatusFound,
+			NoStore:    true,
+		}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+)
+
+func TestCheckSchedule(t *testing.T) {
+	now := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		comingSoonURL string
+		notBefore     time.Time
+		notAfter      time.Time
+		expectedURL   string
+		expectedErr   error
+	}{
+		{
+			name: "Check link without schedule is active",
+		},
+		{
+			name:      "Check link inside its window is active",
+			notBefore: now.Add(-time.Hour),
+			notAfter:  now.Add(time.Hour),
+		},
+		{
+			name:        "Check link before activation is not found",
+			notBefore:   now.Add(time.Hour),
+			expectedErr: customerrors.ErrLinkNotActive,
+		},
+		{
+			name:          "Check link before activation leads to coming soon page",
+			comingSoonURL: "https://example.com/soon",
+			notBefore:     now.Add(time.Hour),
+			expectedURL:   "https://example.com/soon",
+		},
+		{
+			name:        "Check link is expired at not_after",
+			notAfter:    now,
+			expectedErr: customerrors.ErrLinkExpired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ShortURLService{comingSoonURL: tt.comingSoonURL}
+			record := commontypes.URLRecord{LinkOptions: commontypes.LinkOptions{NotBefore: tt.notBefore, NotAfter: tt.notAfter}}
+
+			redirect, err := s.checkSchedule(record, now)
+			assert.ErrorIs(t, err, tt.expectedErr)
+
+			if tt.expectedURL == "" {
+				assert.Nil(t, redirect)
+				return
+			}
+
+			if assert.NotNil(t, redirect) {
+				assert.Equal(t, tt.expectedURL, redirect.URL)
+				assert.Equal(t, http.StatusFound, redirect.StatusCode)
+				assert.True(t, redirect.NoStore)
+			}
+		})
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	now := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, validateSchedule(time.Time{}, time.Time{}, now))
+	assert.NoError(t, validateSchedule(now.Add(time.Hour), time.Time{}, now))
+	assert.NoError(t, validateSchedule(now.Add(-time.Hour), now.Add(time.Hour), now))
+	assert.Error(t, validateSchedule(time.Time{}, now.Add(-time.Hour), now))
+	assert.Error(t, validateSchedule(now.Add(2*time.Hour), now.Add(time.Hour), now))
+}
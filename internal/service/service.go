@@ -7,7 +7,22 @@ import (
 )
 
 type Service interface {
-	MakeShortURL(ctx context.Context, trueURL string) (string, error)
-	GetTrueURL(ctx context.Context, id string) (string, error)
+	MakeShortURL(ctx context.Context, trueURL string, options commontypes.LinkOptions) (string, error)
+	GetTrueURL(ctx context.Context, id string, visit commontypes.Visit) (commontypes.Redirect, error)
+	GetURLRecord(ctx context.Context, id string) (commontypes.URLRecord, error)
+	GetOwnedURLRecord(ctx context.Context, id string) (commontypes.URLRecord, error)
+	SetVariants(ctx context.Context, id string, variants []commontypes.Variant) ([]commontypes.Variant, error)
+	UpdateURL(ctx context.Context, id string, newURL string) (commontypes.URLRecord, error)
+	GetURLHistory(ctx context.Context, id string) ([]commontypes.HistoryEntry, error)
+	ListUserURLs(ctx context.Context, query commontypes.ListQuery) (commontypes.URLPage, error)
+	ListURLs(ctx context.Context, after *commontypes.ListCursor, limit int) (commontypes.URLPage, error)
+	DeleteURL(ctx context.Context, id string) error
+	ForceDeleteURL(ctx context.Context, id string) error
+	GetStats(ctx context.Context) (commontypes.Stats, error)
+	CreateAPIKey(ctx context.Context, name string, scopes []string) (commontypes.APIKey, string, error)
+	ListAPIKeys(ctx context.Context) ([]commontypes.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) error
+	AuthenticateAPIKey(ctx context.Context, key string) (commontypes.APIKey, error)
+	GetQuota(ctx context.Context) (commontypes.QuotaReport, error)
 	MakeShortURLBatch(ctx context.Context, recordsIn []commontypes.RecordToBatch) ([]commontypes.BatchRecord, error)
 }
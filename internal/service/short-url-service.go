@@ -3,77 +3,706 @@ package service
 import (
 	"context"
 	"crypto/md5"
+	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
+	"time"
 
+	"github.com/with0p/golang-url-shortener.git/internal/auth"
 	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	"github.com/with0p/golang-url-shortener.git/internal/config"
 	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+	"github.com/with0p/golang-url-shortener.git/internal/geoip"
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+	"github.com/with0p/golang-url-shortener.git/internal/ratelimiter"
 	"github.com/with0p/golang-url-shortener.git/internal/storage"
 )
 
+const defaultRedirectType = http.StatusTemporaryRedirect
+
+const maxRandomIDAttempts = 3
+
+var allowedRedirectTypes = map[int]bool{
+	http.StatusMovedPermanently:  true,
+	http.StatusFound:             true,
+	http.StatusTemporaryRedirect: true,
+	http.StatusPermanentRedirect: true,
+}
+
 type ShortURLService struct {
-	storage      storage.Storage
-	shortURLHost string
+	storage             storage.Storage
+	shortURLHost        string
+	urlPolicy           *URLPolicy
+	canonicalizer       *URLCanonicalizer
+	defaultRedirectType int
+	queryPrecedence     string
+	geoIP               *geoip.Reader
+	passwordLimiter     *ratelimiter.RateLimiter
+	comingSoonURL       string
+	userQuota           QuotaLimits
+	apiKeyQuota         QuotaLimits
 }
 
-func NewShortURLService(currentStorage storage.Storage, shortURLHost string) *ShortURLService {
+func NewShortURLService(currentStorage storage.Storage, config *config.Config) *ShortURLService {
+	redirectType := config.DefaultRedirectType
+	if !allowedRedirectTypes[redirectType] {
+		if redirectType != 0 {
+			logger.LogError(fmt.Errorf("redirect type %d is not supported, using %d", redirectType, defaultRedirectType))
+		}
+		redirectType = defaultRedirectType
+	}
+
+	queryPrecedence := config.QueryPrecedence
+	if !isValidQueryPrecedence(queryPrecedence) {
+		if queryPrecedence != "" {
+			logger.LogError(fmt.Errorf("query precedence %q is not supported, using %q", queryPrecedence, QueryPrecedenceLink))
+		}
+		queryPrecedence = QueryPrecedenceLink
+	}
+
+	passwordAttempts := config.PasswordAttempts
+	if passwordAttempts <= 0 {
+		passwordAttempts = defaultPasswordAttempts
+	}
+
 	return &ShortURLService{
 		storage:      currentStorage,
-		shortURLHost: shortURLHost,
+		shortURLHost: config.ShortURL,
+		urlPolicy: NewURLPolicy(URLPolicyOptions{
+			AllowedSchemes:      config.AllowedSchemes,
+			MaxLength:           config.MaxURLLength,
+			AllowPrivateHosts:   config.AllowPrivateHosts,
+			ShortURLHost:        config.ShortURL,
+			DomainBlocklistPath: config.DomainBlocklistPath,
+			DomainAllowlistPath: config.DomainAllowlistPath,
+		}),
+		canonicalizer: NewURLCanonicalizer(URLCanonicalizerOptions{
+			Enabled:             config.CanonicalizeURLs,
+			StripTrackingParams: config.StripTrackingParams,
+			TrackingParams:      config.TrackingParams,
+		}),
+		defaultRedirectType: redirectType,
+		queryPrecedence:     queryPrecedence,
+		geoIP:               openGeoIP(config.GeoIPDatabasePath),
+		passwordLimiter:     ratelimiter.NewRateLimiter(passwordAttempts, 0, nil),
+		comingSoonURL:       config.ComingSoonURL,
+		userQuota: QuotaLimits{
+			LinksPerDay: int64(config.QuotaLinksPerDay),
+			ActiveLinks: int64(config.QuotaActiveLinks),
+			BatchSize:   int64(config.QuotaBatchSize),
+		},
+		apiKeyQuota: QuotaLimits{
+			LinksPerDay: int64(config.QuotaKeyLinksPerDay),
+			BatchSize:   int64(config.QuotaKeyBatchSize),
+		},
+	}
+}
+
+func (s *ShortURLService) GetTrueURL(ctx context.Context, id string, visit commontypes.Visit) (commontypes.Redirect, error) {
+	record, err := s.storage.Read(ctx, id)
+	if err != nil {
+		return commontypes.Redirect{}, err
+	}
+
+	comingSoon, err := s.checkSchedule(record, time.Now())
+	if err != nil {
+		return commontypes.Redirect{}, err
+	}
+	if comingSoon != nil {
+		return *comingSoon, nil
+	}
+
+	if record.MaxClicks > 0 && record.ClicksLeft <= 0 {
+		return commontypes.Redirect{}, customerrors.ErrLinkExhausted
+	}
+
+	if record.PasswordHash != "" {
+		if err := s.checkPassword(id, record.PasswordHash, visit); err != nil {
+			return commontypes.Redirect{}, err
+		}
+	}
+
+	country := s.country(visit.ClientIP)
+
+	destination, routed := routeByDevice(record.DeviceRules, visit.UserAgent)
+	if !routed {
+		destination, routed = routeByCountry(record.GeoRules, country)
+	}
+
+	var variant string
+	if !routed {
+		destination = record.FullURL
+		if len(record.Variants) > 0 {
+			picked := pickVariant(record.Variants, visit.Variant)
+			destination = picked.URL
+			variant = picked.ID
+		}
+	}
+
+	destination, err = applyUTM(destination, record.UTM)
+	if err != nil {
+		return commontypes.Redirect{}, err
+	}
+
+	destination, err = applyPassthrough(destination, record.LinkOptions, visit, s.queryPrecedence)
+	if err != nil {
+		return commontypes.Redirect{}, err
+	}
+
+	if record.MaxClicks > 0 {
+		if _, err := s.storage.ConsumeClick(ctx, id); err != nil {
+			return commontypes.Redirect{}, err
+		}
+	}
+
+	if err := s.storage.RegisterClick(ctx, id, commontypes.Click{Variant: variant, Country: country}); err != nil {
+		logger.LogError(err)
+	}
+
+	redirect := commontypes.Redirect{
+		URL:        destination,
+		StatusCode: record.RedirectType,
+		Variant:    variant,
+		Private:    len(record.GeoRules) > 0,
+		NoStore:    record.PasswordHash != "" || record.MaxClicks > 0 || !record.NotAfter.IsZero(),
+	}
+
+	if redirect.StatusCode == 0 {
+		redirect.StatusCode = s.defaultRedirectType
+	}
+
+	return redirect, nil
+}
+
+// SetVariants replaces the destinations of a link owned by the user of the context.
+func (s *ShortURLService) SetVariants(ctx context.Context, id string, variants []commontypes.Variant) ([]commontypes.Variant, error) {
+	if _, err := s.ownedRecord(ctx, id); err != nil {
+		return nil, err
+	}
+
+	prepared, err := s.prepareVariants(variants)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.storage.SetVariants(ctx, id, prepared); err != nil {
+		logger.LogError(err)
+		return nil, errors.New("could not save variants")
+	}
+
+	return prepared, nil
+}
+
+// UpdateURL changes the destination of a link owned by the user of the context.
+func (s *ShortURLService) UpdateURL(ctx context.Context, id string, newURL string) (commontypes.URLRecord, error) {
+	record, err := s.ownedRecord(ctx, id)
+	if err != nil {
+		return commontypes.URLRecord{}, err
 	}
+
+	parsedURL, urlParseError := url.ParseRequestURI(newURL)
+	if urlParseError != nil {
+		return commontypes.URLRecord{}, errors.New("not a URL")
+	}
+
+	newURL = s.canonicalizer.Canonicalize(parsedURL)
+
+	if err := s.urlPolicy.Check(newURL, parsedURL); err != nil {
+		return commontypes.URLRecord{}, err
+	}
+
+	if newURL == record.FullURL {
+		return record, nil
+	}
+
+	if _, err := s.storage.UpdateURL(ctx, id, newURL, editor(ctx)); err != nil {
+		logger.LogError(err)
+		return commontypes.URLRecord{}, errors.New("could not update URL record")
+	}
+
+	record.FullURL = newURL
+	return record, nil
+}
+
+func (s *ShortURLService) GetURLHistory(ctx context.Context, id string) ([]commontypes.HistoryEntry, error) {
+	if _, err := s.ownedRecord(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.storage.ReadHistory(ctx, id)
+}
+
+// ListUserURLs returns a page of the links of the user of the context.
+func (s *ShortURLService) ListUserURLs(ctx context.Context, query commontypes.ListQuery) (commontypes.URLPage, error) {
+	userID := auth.UserIDFromContext(ctx)
+	if userID == "" {
+		return commontypes.URLPage{}, customerrors.ErrUnauthorized
+	}
+
+	query, err := normalizeListQuery(query)
+	if err != nil {
+		return commontypes.URLPage{}, err
+	}
+
+	// One more record than asked tells whether there is a next page.
+	limit := query.Limit
+	query.Limit++
+
+	records, err := s.storage.ListByUser(ctx, userID, query)
+	if err != nil {
+		logger.LogError(err)
+		return commontypes.URLPage{}, errors.New("could not list URL records")
+	}
+
+	page := commontypes.URLPage{Records: records}
+	if len(records) > limit {
+		page.Records = records[:limit]
+		next := records[limit-1].ListCursor()
+		page.Next = &next
+	}
+
+	return page, nil
+}
+
+// ListURLs returns a page of the links of all users in creation order.
+func (s *ShortURLService) ListURLs(ctx context.Context, after *commontypes.ListCursor, limit int) (commontypes.URLPage, error) {
+	switch {
+	case limit <= 0:
+		limit = defaultListLimit
+	case limit > maxListLimit:
+		limit = maxListLimit
+	}
+
+	records, err := s.storage.List(ctx, after, limit+1)
+	if err != nil {
+		logger.LogError(err)
+		return commontypes.URLPage{}, errors.New("could not list URL records")
+	}
+
+	page := commontypes.URLPage{Records: records}
+	if len(records) > limit {
+		page.Records = records[:limit]
+		next := records[limit-1].ListCursor()
+		page.Next = &next
+	}
+
+	return page, nil
+}
+
+// DeleteURL deletes the link of the user of the context.
+func (s *ShortURLService) DeleteURL(ctx context.Context, id string) error {
+	if _, err := s.ownedRecord(ctx, id); err != nil {
+		return err
+	}
+
+	if err := s.storage.Delete(ctx, id); err != nil && !errors.Is(err, customerrors.ErrNotFound) {
+		logger.LogError(err)
+		return errors.New("could not delete URL record")
+	}
+	return nil
+}
+
+// ForceDeleteURL deletes the link whoever owns it.
+func (s *ShortURLService) ForceDeleteURL(ctx context.Context, id string) error {
+	err := s.storage.Delete(ctx, id)
+	if errors.Is(err, customerrors.ErrNotFound) {
+		return fmt.Errorf("%w: %s", customerrors.ErrNotFound, id)
+	}
+	if err != nil {
+		logger.LogError(err)
+		return errors.New("could not delete URL record")
+	}
+	return nil
+}
+
+func (s *ShortURLService) GetStats(ctx context.Context) (commontypes.Stats, error) {
+	stats, err := s.storage.Stats(ctx)
+	if err != nil {
+		logger.LogError(err)
+		return commontypes.Stats{}, errors.New("could not count URL records")
+	}
+	return stats, nil
+}
+
+func (s *ShortURLService) ownedRecord(ctx context.Context, id string) (commontypes.URLRecord, error) {
+	userID := auth.UserIDFromContext(ctx)
+	if userID == "" {
+		return commontypes.URLRecord{}, customerrors.ErrUnauthorized
+	}
+
+	record, err := s.storage.Read(ctx, id)
+	if err != nil {
+		return commontypes.URLRecord{}, fmt.Errorf("%w: %s", customerrors.ErrNotFound, id)
+	}
+
+	if record.UserID != userID {
+		return commontypes.URLRecord{}, customerrors.ErrForbidden
+	}
+
+	return record, nil
 }
 
-func (s *ShortURLService) GetTrueURL(ctx context.Context, id string) (string, error) {
+// isEditedLink reports whether the id derived from the URL belongs to a link whose
+// destination has been changed since, such a link must not be handed out for the URL.
+func (s *ShortURLService) isEditedLink(ctx context.Context, id string, fullURL string) bool {
+	record, err := s.storage.Read(ctx, id)
+	return err == nil && record.FullURL != fullURL
+}
+
+func (s *ShortURLService) GetURLRecord(ctx context.Context, id string) (commontypes.URLRecord, error) {
 	return s.storage.Read(ctx, id)
 }
 
-func (s *ShortURLService) MakeShortURL(ctx context.Context, trueURL string) (string, error) {
-	_, urlParseError := url.ParseRequestURI(trueURL)
+// GetOwnedURLRecord returns the link with its analytics to its owner only.
+func (s *ShortURLService) GetOwnedURLRecord(ctx context.Context, id string) (commontypes.URLRecord, error) {
+	return s.ownedRecord(ctx, id)
+}
+
+func (s *ShortURLService) MakeShortURL(ctx context.Context, trueURL string, options commontypes.LinkOptions) (string, error) {
+	parsedURL, urlParseError := url.ParseRequestURI(trueURL)
 
 	if urlParseError != nil {
 		return "", errors.New("not a URL")
 	}
 
-	shortURLId := generateShortURLId([]byte(trueURL))
+	trueURL = s.canonicalizer.Canonicalize(parsedURL)
+
+	if err := s.urlPolicy.Check(trueURL, parsedURL); err != nil {
+		return "", err
+	}
+
+	if options.RedirectType != 0 && !allowedRedirectTypes[options.RedirectType] {
+		return "", fmt.Errorf("redirect type %d is not supported", options.RedirectType)
+	}
+
+	if options.QueryPrecedence != "" && !isValidQueryPrecedence(options.QueryPrecedence) {
+		return "", fmt.Errorf("query precedence %q is not supported", options.QueryPrecedence)
+	}
+
+	if err := validateUTM(options.UTM); err != nil {
+		return "", err
+	}
+
+	if err := s.validateDeviceRules(options.DeviceRules); err != nil {
+		return "", err
+	}
+
+	geoRules, err := s.prepareGeoRules(options.GeoRules)
+	if err != nil {
+		return "", err
+	}
+	options.GeoRules = geoRules
+
+	if err := validateSchedule(options.NotBefore, options.NotAfter, time.Now()); err != nil {
+		return "", err
+	}
+
+	if err := prepareMetadata(&options); err != nil {
+		return "", err
+	}
+
+	if options.MaxClicks < 0 {
+		return "", errors.New("max clicks must not be negative")
+	}
+
+	if options.Alias != "" {
+		if err := validateAlias(options.Alias); err != nil {
+			return "", err
+		}
+		if err := s.checkAliasFree(ctx, options.Alias, trueURL); err != nil {
+			if errors.Is(err, customerrors.ErrUniqueKeyConstrantViolation) {
+				return s.shortURLHost + "/" + options.Alias, err
+			}
+			return "", err
+		}
+	}
+
+	passwordHash, err := hashPassword(options.Password)
+	if err != nil {
+		return "", err
+	}
+	options.Password = ""
+
+	// The id of a protected link must not be derivable from its URL, and every click
+	// limited link is a link of its own, so they get random ids.
+	randomID := passwordHash != "" || options.MaxClicks > 0
+
+	if !randomID && options.Alias == "" {
+		if id, ok := s.ownSharedLink(ctx, trueURL, options); ok {
+			return s.shortURLHost + "/" + id, customerrors.ErrUniqueKeyConstrantViolation
+		}
+	}
+
+	reservation, err := s.reserveLinks(ctx, 1)
+	if err != nil {
+		return "", err
+	}
+
+	for attempt := 1; ; attempt++ {
+		shortURLId := options.Alias
+		if shortURLId == "" {
+			idSource := shortURLIdSource(trueURL, options, idOwner(ctx))
+			if randomID {
+				idSource = append(idSource, randomBytes()...)
+			}
+			shortURLId = generateShortURLId(idSource)
+
+			if !randomID && s.isEditedLink(ctx, shortURLId, trueURL) {
+				randomID = true
+				continue
+			}
+		}
+
+		record := commontypes.URLRecord{
+			ShortURLKey:  shortURLId,
+			FullURL:      trueURL,
+			UserID:       auth.UserIDFromContext(ctx),
+			PasswordHash: passwordHash,
+			ClicksLeft:   options.MaxClicks,
+			LinkOptions:  options,
+		}
+
+		err := s.storage.Write(ctx, record)
+		if err == nil {
+			reservation.done(1)
+			return s.shortURLHost + "/" + shortURLId, nil
+		}
 
-	if err := s.storage.Write(ctx, shortURLId, trueURL); err != nil {
 		if errors.Is(err, customerrors.ErrUniqueKeyConstrantViolation) {
-			return s.shortURLHost + "/" + shortURLId, err
+			if options.Alias != "" {
+				reservation.done(0)
+				return "", fmt.Errorf("%w: %s", customerrors.ErrAliasTaken, options.Alias)
+			}
+			if randomID && attempt < maxRandomIDAttempts {
+				continue
+			}
+			if !randomID {
+				reservation.done(0)
+				return s.shortURLHost + "/" + shortURLId, err
+			}
 		}
+
+		reservation.done(0)
 		return "", errors.New("could not make URL record")
 	}
-
-	return s.shortURLHost + "/" + shortURLId, nil
 }
 
+// MakeShortURLBatch makes the links it can, the records of the others tell why they
+// were not made. Links which exist already keep their short URL.
 func (s *ShortURLService) MakeShortURLBatch(ctx context.Context, recordsIn []commontypes.RecordToBatch) ([]commontypes.BatchRecord, error) {
+	if err := s.checkBatchSize(ctx, len(recordsIn)); err != nil {
+		return nil, err
+	}
+
 	batchData := make([]commontypes.BatchRecord, len(recordsIn))
+	owner := idOwner(ctx)
+	lookup := make([]string, 0, 2*len(recordsIn))
 
 	for i, reqRec := range recordsIn {
-		_, urlParseError := url.ParseRequestURI(reqRec.FullURL)
+		batchData[i].ID = reqRec.ID
 
+		parsedURL, urlParseError := url.ParseRequestURI(reqRec.FullURL)
 		if urlParseError != nil {
+			batchData[i].Err = errors.New("not a valid URL")
 			continue
 		}
 
-		shortURLId := generateShortURLId([]byte(reqRec.FullURL))
+		fullURL := s.canonicalizer.Canonicalize(parsedURL)
+
+		if err := s.urlPolicy.Check(fullURL, parsedURL); err != nil {
+			batchData[i].Err = err
+			continue
+		}
+
+		batchData[i].FullURL = fullURL
+		batchData[i].ShortURLKey = generateShortURLId(shortURLIdSource(fullURL, commontypes.LinkOptions{}, owner))
+		lookup = append(lookup, batchData[i].ShortURLKey)
+		if owner != "" {
+			lookup = append(lookup, generateShortURLId(shortURLIdSource(fullURL, commontypes.LinkOptions{}, "")))
+		}
+	}
+
+	// The links the records may have already are looked up at once, not one by one.
+	existing, err := s.storage.ReadMany(ctx, lookup)
+	if err != nil {
+		logger.LogError(err)
+		return nil, errors.New("could not make Batch URL record")
+	}
+
+	toWrite := make([]int, 0, len(recordsIn))
+	keys := make(map[string]bool, len(recordsIn))
+
+	for i := range batchData {
+		if batchData[i].Err != nil {
+			continue
+		}
+
+		fullURL := batchData[i].FullURL
+		shortURLId := batchData[i].ShortURLKey
+		exists := false
+
+		// The link the user made before having a cookie, see ownSharedLink.
+		sharedID := generateShortURLId(shortURLIdSource(fullURL, commontypes.LinkOptions{}, ""))
+		if record, ok := existing[sharedID]; ok && owner != "" && record.UserID == owner && record.FullURL == fullURL {
+			shortURLId, exists = sharedID, true
+		} else if record, ok := existing[shortURLId]; ok {
+			// A link whose destination has been edited is not handed out for the URL.
+			exists = record.FullURL == fullURL
+			if !exists {
+				shortURLId = generateShortURLId(append([]byte(fullURL), randomBytes()...))
+			}
+		}
 
 		batchData[i] = commontypes.BatchRecord{
-			ID:          reqRec.ID,
+			ID:          batchData[i].ID,
 			ShortURLKey: shortURLId,
 			ShortURL:    s.shortURLHost + "/" + shortURLId,
-			FullURL:     reqRec.FullURL,
+			FullURL:     fullURL,
+			UserID:      auth.UserIDFromContext(ctx),
 		}
+
+		// Existing links and the same URL given twice are not written again.
+		if exists || keys[shortURLId] {
+			batchData[i].Err = customerrors.ErrUniqueKeyConstrantViolation
+			continue
+		}
+		keys[shortURLId] = true
+		toWrite = append(toWrite, i)
 	}
 
-	if err := s.storage.WriteBatch(ctx, batchData); err != nil {
+	reservation, err := s.reserveLinks(ctx, int64(len(toWrite)))
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.writeBatch(ctx, batchData, toWrite)
+	reservation.done(stored)
+	if err != nil {
+		logger.LogError(err)
 		return nil, errors.New("could not make Batch URL record")
 	}
 
 	return batchData, nil
 }
 
+// writeBatch stores the records at the indexes in one go. When some of them exist it
+// falls back to storing them one by one, marking those which exist.
+func (s *ShortURLService) writeBatch(ctx context.Context, batchData []commontypes.BatchRecord, indexes []int) (int64, error) {
+	records := make([]commontypes.URLRecord, len(indexes))
+	for i, index := range indexes {
+		records[i] = commontypes.URLRecord{
+			ShortURLKey: batchData[index].ShortURLKey,
+			FullURL:     batchData[index].FullURL,
+			UserID:      batchData[index].UserID,
+		}
+	}
+
+	err := s.storage.WriteBatch(ctx, records)
+	if err == nil {
+		return int64(len(records)), nil
+	}
+	if !errors.Is(err, customerrors.ErrUniqueKeyConstrantViolation) {
+		return 0, err
+	}
+
+	var stored int64
+	for i, index := range indexes {
+		err := s.storage.Write(ctx, records[i])
+		switch {
+		case err == nil:
+			stored++
+		case errors.Is(err, customerrors.ErrUniqueKeyConstrantViolation):
+			batchData[index].Err = err
+		default:
+			return stored, err
+		}
+	}
+
+	return stored, nil
+}
+
+// ownSharedLink finds the link the user made for the URL before having an identity of
+// its own, so that it is handed out again rather than a second link made.
+func (s *ShortURLService) ownSharedLink(ctx context.Context, trueURL string, options commontypes.LinkOptions) (string, bool) {
+	userID := idOwner(ctx)
+	if userID == "" {
+		return "", false
+	}
+
+	id := generateShortURLId(shortURLIdSource(trueURL, options, ""))
+	record, err := s.storage.Read(ctx, id)
+	return id, err == nil && record.UserID == userID && record.FullURL == trueURL
+}
+
+// idOwner is the owner scoping the ids of the links. Clients which have just been
+// given an identity share the links of a URL with each other, so that shortening the
+// same URL without a cookie keeps giving the same link.
+func idOwner(ctx context.Context) string {
+	identity, ok := auth.IdentityFromContext(ctx)
+	if !ok || identity.Issued {
+		return ""
+	}
+	return identity.UserID
+}
+
+// shortURLIdSource adds the options changing where the link leads to the URL, so that
+// links to the same destination with e.g. different UTM sets are different links. The
+// owner is added too, the same URL shortened by another user is a link of its own.
+func shortURLIdSource(trueURL string, options commontypes.LinkOptions, userID string) []byte {
+	idSource := trueURL
+
+	if userID != "" {
+		idSource += "\nuser:" + userID
+	}
+
+	if len(options.UTM) > 0 {
+		idSource += "\n" + utmValues(options.UTM).Encode()
+	}
+
+	if len(options.DeviceRules) > 0 {
+		rules, _ := json.Marshal(options.DeviceRules)
+		idSource += "\n" + string(rules)
+	}
+
+	if len(options.GeoRules) > 0 {
+		rules, _ := json.Marshal(options.GeoRules)
+		idSource += "\n" + string(rules)
+	}
+
+	if !options.NotBefore.IsZero() || !options.NotAfter.IsZero() {
+		idSource += "\n" + options.NotBefore.UTC().Format(time.RFC3339) + "/" + options.NotAfter.UTC().Format(time.RFC3339)
+	}
+
+	if options.RedirectType != 0 {
+		idSource += fmt.Sprintf("\nredirect:%d", options.RedirectType)
+	}
+
+	if options.PassQuery {
+		idSource += "\npass_query:" + options.QueryPrecedence
+	}
+
+	if options.PassPath {
+		idSource += "\npass_path"
+	}
+
+	if options.Title != "" || options.Notes != "" || len(options.Tags) > 0 {
+		metadata, _ := json.Marshal([]any{options.Title, options.Notes, options.Tags})
+		idSource += "\n" + string(metadata)
+	}
+
+	return []byte(idSource)
+}
+
+func randomBytes() []byte {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return b
+}
+
 func generateShortURLId(fullURLByte []byte) string {
 	hash := md5.New()
 	hash.Write(fullURLByte)
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/with0p/golang-url-shortener.git/internal/auth"
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	"github.com/with0p/golang-url-shortener.git/internal/config"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+	"github.com/with0p/golang-url-shortener.git/internal/storage"
+)
+
+// countingStorage counts the lookups of links and the updates of the usage.
+type countingStorage struct {
+	*storage.InMemoryStorage
+	reads     int
+	readManys int
+	addUsages int
+}
+
+func (s *countingStorage) Read(ctx context.Context, shortURLKey string) (commontypes.URLRecord, error) {
+	s.reads++
+	return s.InMemoryStorage.Read(ctx, shortURLKey)
+}
+
+func (s *countingStorage) ReadMany(ctx context.Context, shortURLKeys []string) (map[string]commontypes.URLRecord, error) {
+	s.readManys++
+	return s.InMemoryStorage.ReadMany(ctx, shortURLKeys)
+}
+
+func (s *countingStorage) AddUsage(ctx context.Context, subject string, period string, n int64, limit int64) (int64, error) {
+	s.addUsages++
+	return s.InMemoryStorage.AddUsage(ctx, subject, period, n, limit)
+}
+
+func TestMakeShortURLBatchLooksUpOnce(t *testing.T) {
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{UserID: "user"})
+	counting := &countingStorage{InMemoryStorage: storage.NewInMemoryStorage(storage.URLStorageMap{})}
+	service := NewShortURLService(counting, config.MockConfiguration)
+
+	_, err := service.MakeShortURL(ctx, "https://practicum.yandex.kz/0", commontypes.LinkOptions{})
+	require.Nil(t, err)
+	counting.reads = 0
+
+	records := make([]commontypes.RecordToBatch, 100)
+	for i := range records {
+		records[i] = commontypes.RecordToBatch{ID: fmt.Sprint(i), FullURL: fmt.Sprintf("https://practicum.yandex.kz/%d", i)}
+	}
+
+	result, err := service.MakeShortURLBatch(ctx, records)
+	require.Nil(t, err)
+	require.Len(t, result, len(records))
+
+	assert.Equal(t, 0, counting.reads)
+	assert.Equal(t, 1, counting.readManys)
+	assert.ErrorIs(t, result[0].Err, customerrors.ErrUniqueKeyConstrantViolation)
+	for _, record := range result[1:] {
+		assert.Nil(t, record.Err)
+	}
+}
+
+func TestMakeShortURLCountsUsageUnderLimit(t *testing.T) {
+	tests := []struct {
+		name              string
+		linksPerDay       int
+		expectedAddUsages int
+	}{
+		{name: "Check usage is not counted without a limit", linksPerDay: 0, expectedAddUsages: 0},
+		{name: "Check usage is counted under a limit", linksPerDay: 10, expectedAddUsages: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := *config.MockConfiguration
+			conf.QuotaLinksPerDay = tt.linksPerDay
+
+			ctx := auth.WithIdentity(context.Background(), auth.Identity{UserID: "user"})
+			counting := &countingStorage{InMemoryStorage: storage.NewInMemoryStorage(storage.URLStorageMap{})}
+			service := NewShortURLService(counting, &conf)
+
+			for i := 0; i < 2; i++ {
+				_, err := service.MakeShortURL(ctx, fmt.Sprintf("https://practicum.yandex.kz/%d", i), commontypes.LinkOptions{})
+				require.Nil(t, err)
+			}
+
+			assert.Equal(t, tt.expectedAddUsages, counting.addUsages)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+var defaultTrackingParams = []string{"utm_*", "fbclid", "gclid", "yclid", "mc_cid", "mc_eid"}
+
+type URLCanonicalizer struct {
+	enabled        bool
+	trackingParams []string
+}
+
+type URLCanonicalizerOptions struct {
+	Enabled             bool
+	StripTrackingParams bool
+	// Parameter names to strip, a trailing "*" matches any suffix.
+	TrackingParams []string
+}
+
+func NewURLCanonicalizer(options URLCanonicalizerOptions) *URLCanonicalizer {
+	canonicalizer := &URLCanonicalizer{enabled: options.Enabled}
+
+	if options.StripTrackingParams {
+		canonicalizer.trackingParams = options.TrackingParams
+		if len(canonicalizer.trackingParams) == 0 {
+			canonicalizer.trackingParams = defaultTrackingParams
+		}
+	}
+
+	return canonicalizer
+}
+
+// Canonicalize brings equivalent URLs to the same form so that they get the same short
+// URL id. The parsed URL is modified in place.
+func (canonicalizer *URLCanonicalizer) Canonicalize(parsedURL *url.URL) string {
+	if !canonicalizer.enabled || parsedURL.Opaque != "" {
+		return parsedURL.String()
+	}
+
+	parsedURL.Scheme = strings.ToLower(parsedURL.Scheme)
+
+	host := toASCIIHost(strings.ToLower(parsedURL.Hostname()))
+	if port := parsedURL.Port(); port != "" && port != defaultPorts[parsedURL.Scheme] {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	parsedURL.Host = host
+
+	if parsedURL.Path == "" && parsedURL.Host != "" {
+		parsedURL.Path = "/"
+	}
+
+	query := parsedURL.Query()
+	for param := range query {
+		if canonicalizer.isTrackingParam(param) {
+			query.Del(param)
+		}
+	}
+	parsedURL.RawQuery = query.Encode()
+	parsedURL.ForceQuery = false
+
+	return parsedURL.String()
+}
+
+func (canonicalizer *URLCanonicalizer) isTrackingParam(param string) bool {
+	param = strings.ToLower(param)
+	for _, pattern := range canonicalizer.trackingParams {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(param, prefix) {
+			return true
+		}
+		if param == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// toASCIIHost converts an internationalized host name to its ASCII form. Host names
+// IDNA rejects, e.g. those with underscores, are left as they are.
+func toASCIIHost(host string) string {
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return host
+	}
+	return ascii
+}
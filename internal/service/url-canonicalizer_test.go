@@ -0,0 +1,84 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name                string
+		disabled            bool
+		stripTrackingParams bool
+		url                 string
+		expectedURL         string
+	}{
+		{
+			name:        "Check URL is kept when disabled",
+			disabled:    true,
+			url:         "HTTPS://Example.com/a?b=1&a=2",
+			expectedURL: "https://Example.com/a?b=1&a=2",
+		},
+		{
+			name:        "Check host case and query order",
+			url:         "HTTPS://Example.com/a?b=1&a=2",
+			expectedURL: "https://example.com/a?a=2&b=1",
+		},
+		{
+			name:        "Check default port is stripped",
+			url:         "http://example.com:80/a",
+			expectedURL: "http://example.com/a",
+		},
+		{
+			name:        "Check non default port is kept",
+			url:         "https://example.com:8443/a",
+			expectedURL: "https://example.com:8443/a",
+		},
+		{
+			name:        "Check empty path",
+			url:         "https://example.com",
+			expectedURL: "https://example.com/",
+		},
+		{
+			name:        "Check IDN host",
+			url:         "https://München.de/straße",
+			expectedURL: "https://xn--mnchen-3ya.de/stra%C3%9Fe",
+		},
+		{
+			name:        "Check tracking params are kept by default",
+			url:         "https://example.com/?utm_source=mail&id=1",
+			expectedURL: "https://example.com/?id=1&utm_source=mail",
+		},
+		{
+			name:                "Check tracking params are stripped",
+			stripTrackingParams: true,
+			url:                 "https://example.com/?utm_source=mail&fbclid=x&id=1",
+			expectedURL:         "https://example.com/?id=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canonicalizer := NewURLCanonicalizer(URLCanonicalizerOptions{
+				Enabled:             !tt.disabled,
+				StripTrackingParams: tt.stripTrackingParams,
+			})
+
+			parsedURL, err := url.ParseRequestURI(tt.url)
+			require.Nil(t, err)
+
+			assert.Equal(t, tt.expectedURL, canonicalizer.Canonicalize(parsedURL))
+		})
+	}
+}
+
+func TestToASCIIHost(t *testing.T) {
+	assert.Equal(t, "xn--mnchen-3ya.de", toASCIIHost("münchen.de"))
+	assert.Equal(t, "xn--bcher-kva.example", toASCIIHost("bücher.example"))
+	assert.Equal(t, "xn--d1acufc.xn--p1ai", toASCIIHost("домен.рф"))
+	assert.Equal(t, "practicum.yandex.kz", toASCIIHost("practicum.yandex.kz"))
+	assert.Equal(t, "my_host.example", toASCIIHost("my_host.example"))
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+)
+
+const domainListReloadInterval = 5 * time.Second
+
+var defaultAllowedSchemes = []string{"http", "https"}
+
+type URLPolicy struct {
+	allowedSchemes    map[string]bool
+	maxLength         int
+	allowPrivateHosts bool
+	selfHost          string
+	blocklist         *domainList
+	allowlist         *domainList
+}
+
+type URLPolicyOptions struct {
+	AllowedSchemes      []string
+	MaxLength           int
+	AllowPrivateHosts   bool
+	ShortURLHost        string
+	DomainBlocklistPath string
+	DomainAllowlistPath string
+}
+
+func NewURLPolicy(options URLPolicyOptions) *URLPolicy {
+	schemes := options.AllowedSchemes
+	if len(schemes) == 0 {
+		schemes = defaultAllowedSchemes
+	}
+
+	policy := &URLPolicy{
+		allowedSchemes:    map[string]bool{},
+		maxLength:         options.MaxLength,
+		allowPrivateHosts: options.AllowPrivateHosts,
+	}
+
+	for _, scheme := range schemes {
+		policy.allowedSchemes[strings.ToLower(scheme)] = true
+	}
+
+	if shortURL, err := url.Parse(options.ShortURLHost); err == nil {
+		policy.selfHost = strings.ToLower(strings.TrimSuffix(shortURL.Hostname(), "."))
+	}
+
+	if options.DomainBlocklistPath != "" {
+		policy.blocklist = newDomainList(options.DomainBlocklistPath)
+	}
+
+	if options.DomainAllowlistPath != "" {
+		policy.allowlist = newDomainList(options.DomainAllowlistPath)
+	}
+
+	return policy
+}
+
+func (policy *URLPolicy) Check(rawURL string, parsedURL *url.URL) error {
+	if policy.maxLength > 0 && len(rawURL) > policy.maxLength {
+		return fmt.Errorf("%w: longer than %d characters", customerrors.ErrURLNotAllowed, policy.maxLength)
+	}
+
+	if !policy.allowedSchemes[strings.ToLower(parsedURL.Scheme)] {
+		return fmt.Errorf("%w: scheme %q", customerrors.ErrURLNotAllowed, parsedURL.Scheme)
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(parsedURL.Hostname(), "."))
+	if host == "" {
+		return fmt.Errorf("%w: no host", customerrors.ErrURLNotAllowed)
+	}
+
+	if policy.selfHost != "" && host == policy.selfHost {
+		return fmt.Errorf("%w: links to the shortener itself", customerrors.ErrURLNotAllowed)
+	}
+
+	if !policy.allowPrivateHosts && isPrivateHost(host) {
+		return fmt.Errorf("%w: private or loopback host %q", customerrors.ErrURLNotAllowed, host)
+	}
+
+	if policy.allowlist != nil && !policy.allowlist.matches(host) {
+		return fmt.Errorf("%w: domain %q is not in the allowlist", customerrors.ErrURLNotAllowed, host)
+	}
+
+	if policy.blocklist != nil && policy.blocklist.matches(host) {
+		return fmt.Errorf("%w: domain %q is blocked", customerrors.ErrURLNotAllowed, host)
+	}
+
+	return nil
+}
+
+func isPrivateHost(host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// domainList is a file with one domain per line. "*.example.com" matches any
+// subdomain of example.com and lines starting with "#" are comments. The file is
+// re-read when its modification time changes.
+type domainList struct {
+	path      string
+	mu        sync.RWMutex
+	patterns  []string
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newDomainList(path string) *domainList {
+	list := &domainList{path: path}
+	list.reload()
+	return list
+}
+
+func (list *domainList) matches(host string) bool {
+	list.reload()
+
+	list.mu.RLock()
+	defer list.mu.RUnlock()
+
+	for _, pattern := range list.patterns {
+		if matchDomain(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (list *domainList) reload() {
+	list.mu.Lock()
+	defer list.mu.Unlock()
+
+	now := time.Now()
+	if !list.checkedAt.IsZero() && now.Sub(list.checkedAt) < domainListReloadInterval {
+		return
+	}
+	list.checkedAt = now
+
+	info, err := os.Stat(list.path)
+	if err != nil {
+		logger.LogError(err)
+		return
+	}
+
+	if info.ModTime().Equal(list.modTime) {
+		return
+	}
+
+	file, err := os.Open(list.path)
+	if err != nil {
+		logger.LogError(err)
+		return
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, strings.TrimSuffix(line, "."))
+	}
+
+	if err := scanner.Err(); err != nil {
+		logger.LogError(err)
+		return
+	}
+
+	list.patterns = patterns
+	list.modTime = info.ModTime()
+	logger.LogInfo(fmt.Sprintf("Loaded %d domains from %s", len(patterns), list.path))
+}
+
+func matchDomain(pattern string, host string) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+
+	return host == pattern
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
+)
+
+func TestURLPolicyCheck(t *testing.T) {
+	blocklistPath := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.Nil(t, os.WriteFile(blocklistPath, []byte("# phishing\nevil.com\n*.tracker.net\n"), 0666))
+
+	policy := NewURLPolicy(URLPolicyOptions{
+		MaxLength:           40,
+		ShortURLHost:        "http://localhost:8080",
+		DomainBlocklistPath: blocklistPath,
+	})
+
+	tests := []struct {
+		name          string
+		url           string
+		errorExpected bool
+	}{
+		{name: "Check regular URL", url: "https://practicum.yandex.kz/", errorExpected: false},
+		{name: "Check javascript scheme", url: "javascript:alert(1)", errorExpected: true},
+		{name: "Check file scheme", url: "file:///etc/passwd", errorExpected: true},
+		{name: "Check too long URL", url: "https://practicum.yandex.kz/" + "aaaaaaaaaaaaaaaa", errorExpected: true},
+		{name: "Check self referencing URL", url: "http://localhost:8080/a0c7ecc8", errorExpected: true},
+		{name: "Check loopback IP", url: "http://127.0.0.1/admin", errorExpected: true},
+		{name: "Check private IP", url: "http://192.168.1.1/", errorExpected: true},
+		{name: "Check blocked domain", url: "https://evil.com/login", errorExpected: true},
+		{name: "Check blocked wildcard subdomain", url: "https://a.tracker.net/", errorExpected: true},
+		{name: "Check wildcard does not match apex", url: "https://tracker.net/", errorExpected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsedURL, err := url.ParseRequestURI(tt.url)
+			require.Nil(t, err)
+
+			err = policy.Check(tt.url, parsedURL)
+			if tt.errorExpected {
+				assert.ErrorIs(t, err, customerrors.ErrURLNotAllowed)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestURLPolicyAllowlist(t *testing.T) {
+	allowlistPath := filepath.Join(t.TempDir(), "allowlist.txt")
+	require.Nil(t, os.WriteFile(allowlistPath, []byte("*.yandex.kz\n"), 0666))
+
+	policy := NewURLPolicy(URLPolicyOptions{DomainAllowlistPath: allowlistPath})
+
+	allowedURL, _ := url.ParseRequestURI("https://practicum.yandex.kz/")
+	assert.Nil(t, policy.Check(allowedURL.String(), allowedURL))
+
+	otherURL, _ := url.ParseRequestURI("https://github.com/")
+	assert.ErrorIs(t, policy.Check(otherURL.String(), otherURL), customerrors.ErrURLNotAllowed)
+}
+
+func TestURLPolicySelfHost(t *testing.T) {
+	policy := NewURLPolicy(URLPolicyOptions{ShortURLHost: "https://sho.rt:8443"})
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "Check self referencing URL without port", url: "https://sho.rt/a0c7ecc8"},
+		{name: "Check self referencing URL with other port", url: "http://sho.rt:80/a0c7ecc8"},
+		{name: "Check self referencing URL in upper case", url: "https://SHO.RT./a0c7ecc8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsedURL, err := url.ParseRequestURI(tt.url)
+			require.Nil(t, err)
+
+			assert.ErrorIs(t, policy.Check(tt.url, parsedURL), customerrors.ErrURLNotAllowed)
+		})
+	}
+}
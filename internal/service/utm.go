@@ -0,0 +1,60 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	utmPrefix     = "utm_"
+	maxUTMParams  = 10
+	maxUTMValueLn = 256
+)
+
+func validateUTM(utm map[string]string) error {
+	if len(utm) > maxUTMParams {
+		return fmt.Errorf("no more than %d UTM parameters allowed", maxUTMParams)
+	}
+
+	for key, value := range utm {
+		if !strings.HasPrefix(key, utmPrefix) || len(key) == len(utmPrefix) {
+			return fmt.Errorf("%q is not a UTM parameter", key)
+		}
+		if value == "" || len(value) > maxUTMValueLn {
+			return fmt.Errorf("UTM parameter %q must be from 1 to %d characters", key, maxUTMValueLn)
+		}
+	}
+
+	return nil
+}
+
+func utmValues(utm map[string]string) url.Values {
+	values := url.Values{}
+	for key, value := range utm {
+		values.Set(key, value)
+	}
+	return values
+}
+
+// applyUTM sets the stored UTM parameters on the destination URL, replacing the
+// ones the destination already has.
+func applyUTM(destination string, utm map[string]string) (string, error) {
+	if len(utm) == 0 {
+		return destination, nil
+	}
+
+	destinationURL, err := url.Parse(destination)
+	if err != nil {
+		return "", errors.New("could not parse destination URL")
+	}
+
+	query := destinationURL.Query()
+	for key, value := range utm {
+		query.Set(key, value)
+	}
+	destinationURL.RawQuery = query.Encode()
+
+	return destinationURL.String(), nil
+}
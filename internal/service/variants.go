@@ -0,0 +1,85 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"regexp"
+	"strconv"
+
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+)
+
+const (
+	maxVariants      = 10
+	maxVariantWeight = 1000
+)
+
+var variantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// prepareVariants validates the variants and gives the ones without an id their
+// position as id.
+func (s *ShortURLService) prepareVariants(variants []commontypes.Variant) ([]commontypes.Variant, error) {
+	if len(variants) > maxVariants {
+		return nil, fmt.Errorf("no more than %d variants allowed", maxVariants)
+	}
+
+	prepared := make([]commontypes.Variant, len(variants))
+	seen := map[string]bool{}
+
+	for i, variant := range variants {
+		if variant.ID == "" {
+			variant.ID = strconv.Itoa(i + 1)
+		}
+
+		if !variantIDPattern.MatchString(variant.ID) {
+			return nil, fmt.Errorf("variant id %q must be 1 to 32 letters, digits, \"-\" or \"_\"", variant.ID)
+		}
+
+		if seen[variant.ID] {
+			return nil, fmt.Errorf("duplicate variant id %q", variant.ID)
+		}
+		seen[variant.ID] = true
+
+		if variant.Weight <= 0 || variant.Weight > maxVariantWeight {
+			return nil, fmt.Errorf("variant weight must be from 1 to %d", maxVariantWeight)
+		}
+
+		parsedURL, err := url.ParseRequestURI(variant.URL)
+		if err != nil {
+			return nil, errors.New("variant URL is not a URL")
+		}
+
+		variant.URL = s.canonicalizer.Canonicalize(parsedURL)
+		if err := s.urlPolicy.Check(variant.URL, parsedURL); err != nil {
+			return nil, err
+		}
+
+		prepared[i] = variant
+	}
+
+	return prepared, nil
+}
+
+// pickVariant keeps the visitor on the variant assigned earlier if it still exists,
+// otherwise picks one at random according to the weights.
+func pickVariant(variants []commontypes.Variant, assigned string) commontypes.Variant {
+	total := 0
+	for _, variant := range variants {
+		if variant.ID == assigned {
+			return variant
+		}
+		total += variant.Weight
+	}
+
+	n := rand.IntN(total)
+	for _, variant := range variants {
+		if n < variant.Weight {
+			return variant
+		}
+		n -= variant.Weight
+	}
+
+	return variants[len(variants)-1]
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+)
+
+type createdIndexEntry struct {
+	createdAt   time.Time
+	shortURLKey string
+}
+
+// createdIndex keeps short URLs sorted by creation time and then by short URL, the order
+// of List. New links are usually the newest ones, so inserting mostly appends.
+type createdIndex struct {
+	entries []createdIndexEntry
+}
+
+func newCreatedIndex(records []commontypes.URLRecord) *createdIndex {
+	index := &createdIndex{entries: make([]createdIndexEntry, 0, len(records))}
+	for _, record := range records {
+		index.entries = append(index.entries, createdIndexEntry{record.CreatedAt, record.ShortURLKey})
+	}
+	slices.SortFunc(index.entries, compareCreatedIndexEntries)
+	return index
+}
+
+func (index *createdIndex) insert(createdAt time.Time, shortURLKey string) {
+	entry := createdIndexEntry{createdAt, shortURLKey}
+	position, found := slices.BinarySearchFunc(index.entries, entry, compareCreatedIndexEntries)
+	if !found {
+		index.entries = slices.Insert(index.entries, position, entry)
+	}
+}
+
+func (index *createdIndex) remove(createdAt time.Time, shortURLKey string) {
+	entry := createdIndexEntry{createdAt, shortURLKey}
+	position, found := slices.BinarySearchFunc(index.entries, entry, compareCreatedIndexEntries)
+	if found {
+		index.entries = slices.Delete(index.entries, position, position+1)
+	}
+}
+
+// after returns up to limit short URLs following the cursor, from the first one when
+// the cursor is nil.
+func (index *createdIndex) after(cursor *commontypes.ListCursor, limit int) []string {
+	start := 0
+	if cursor != nil {
+		entry := createdIndexEntry{cursor.CreatedAt, cursor.ShortURLKey}
+		position, found := slices.BinarySearchFunc(index.entries, entry, compareCreatedIndexEntries)
+		if found {
+			position++
+		}
+		start = position
+	}
+
+	end := len(index.entries)
+	if limit > 0 {
+		end = min(end, start+limit)
+	}
+
+	keys := make([]string, 0, end-start)
+	for _, entry := range index.entries[start:end] {
+		keys = append(keys, entry.shortURLKey)
+	}
+	return keys
+}
+
+func compareCreatedIndexEntries(a, b createdIndexEntry) int {
+	if result := a.createdAt.Compare(b.createdAt); result != 0 {
+		return result
+	}
+	return cmp.Compare(a.shortURLKey, b.shortURLKey)
+}
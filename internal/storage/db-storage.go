@@ -3,7 +3,11 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -11,6 +15,23 @@ import (
 	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
 )
 
+const recordColumns = `short_url_key, full_url, user_id, created_at, clicks, redirect_type,
+	pass_query, query_precedence, pass_path, utm, device_rules, variants, variant_clicks,
+	geo_rules, country_clicks, password_hash, max_clicks, clicks_left,
+	not_before, not_after, title, notes, tags, alias`
+
+const apiKeyColumns = `id, user_id, name, key_hash, scopes, created_at, revoked_at`
+
+var listSortColumns = map[string]string{
+	commontypes.SortCreatedAsc: "created_at",
+	commontypes.SortClicksAsc:  "clicks",
+	commontypes.SortTitleAsc:   "title",
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
 type DBStorage struct {
 	db *sql.DB
 }
@@ -40,6 +61,71 @@ func initTable(ctx context.Context, db *sql.DB) error {
 
 	tr.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS short_url_key_index ON shortener (short_url_key)`)
 
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now()`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS clicks BIGINT NOT NULL DEFAULT 0`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS redirect_type INT NOT NULL DEFAULT 0`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS pass_query BOOLEAN NOT NULL DEFAULT false`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS query_precedence TEXT NOT NULL DEFAULT ''`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS pass_path BOOLEAN NOT NULL DEFAULT false`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS utm JSONB`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS device_rules JSONB`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS variants JSONB`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS variant_clicks JSONB NOT NULL DEFAULT '{}'`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS geo_rules JSONB`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS country_clicks JSONB NOT NULL DEFAULT '{}'`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS password_hash TEXT NOT NULL DEFAULT ''`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS max_clicks BIGINT NOT NULL DEFAULT 0`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS clicks_left BIGINT NOT NULL DEFAULT 0`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS not_before TIMESTAMPTZ`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS not_after TIMESTAMPTZ`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS user_id TEXT NOT NULL DEFAULT ''`)
+	tr.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS user_id_index ON shortener (user_id)`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT ''`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS notes TEXT NOT NULL DEFAULT ''`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS tags JSONB`)
+	tr.ExecContext(ctx, `ALTER TABLE shortener ADD COLUMN IF NOT EXISTS alias TEXT NOT NULL DEFAULT ''`)
+	tr.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS user_created_index ON shortener (user_id, created_at, short_url_key)`)
+	tr.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS tags_index ON shortener USING GIN (tags)`)
+	tr.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS created_index ON shortener (created_at, short_url_key)`)
+
+	tr.ExecContext(ctx, `
+    CREATE TABLE IF NOT EXISTS shortener_history (
+        id BIGSERIAL PRIMARY KEY,
+        short_url_key TEXT NOT NULL,
+        old_url TEXT NOT NULL,
+        new_url TEXT NOT NULL,
+        changed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+        editor TEXT NOT NULL
+    );`)
+	tr.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS shortener_history_key_index ON shortener_history (short_url_key, id)`)
+
+	tr.ExecContext(ctx, `
+    CREATE TABLE IF NOT EXISTS shortener_api_keys (
+        id TEXT PRIMARY KEY,
+        user_id TEXT NOT NULL,
+        name TEXT NOT NULL DEFAULT '',
+        key_hash TEXT NOT NULL,
+        scopes JSONB NOT NULL DEFAULT '[]',
+        created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+        revoked_at TIMESTAMPTZ
+    );`)
+	tr.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS shortener_api_keys_hash_index ON shortener_api_keys (key_hash)`)
+	tr.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS shortener_api_keys_user_index ON shortener_api_keys (user_id)`)
+
+	tr.ExecContext(ctx, `
+    CREATE TABLE IF NOT EXISTS shortener_usage (
+        subject TEXT NOT NULL,
+        period TEXT NOT NULL,
+        used BIGINT NOT NULL DEFAULT 0,
+        PRIMARY KEY (subject, period)
+    );`)
+
+	tr.ExecContext(ctx, `
+    CREATE TABLE IF NOT EXISTS shortener_secrets (
+        name TEXT PRIMARY KEY,
+        value TEXT NOT NULL
+    );`)
+
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -48,39 +134,54 @@ func initTable(ctx context.Context, db *sql.DB) error {
 	}
 }
 
-func (storage *DBStorage) Read(ctx context.Context, shortURLKey string) (string, error) {
+func (storage *DBStorage) Read(ctx context.Context, shortURLKey string) (commontypes.URLRecord, error) {
 	query := `
-	SELECT full_url 
+	SELECT ` + recordColumns + `
 	FROM shortener 
 	WHERE short_url_key = $1;`
 
-	var fullURL string
-	err := storage.db.QueryRowContext(ctx, query, shortURLKey).Scan(&fullURL)
+	record, err := scanRecord(storage.db.QueryRowContext(ctx, query, shortURLKey))
 	if err != nil {
-		return "", err
+		return commontypes.URLRecord{}, err
 	}
 
 	select {
 	case <-ctx.Done():
-		return "", ctx.Err()
+		return commontypes.URLRecord{}, ctx.Err()
 	default:
-		return fullURL, nil
+		return record, nil
 	}
 }
 
-func (storage *DBStorage) Write(ctx context.Context, shortURLKey string, fullURL string) error {
-	queryInsert := `
-    INSERT INTO shortener (full_url, short_url_key) 
-    VALUES ($1, $2);`
-
-	_, errInsert := storage.db.ExecContext(ctx, queryInsert, fullURL, shortURLKey)
-	if errInsert != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(errInsert, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
-			errInsert = customerrors.ErrUniqueKeyConstrantViolation
-		}
+// ReadMany passes the keys as a JSON array, like the other list columns.
+func (storage *DBStorage) ReadMany(ctx context.Context, shortURLKeys []string) (map[string]commontypes.URLRecord, error) {
+	records := make(map[string]commontypes.URLRecord, len(shortURLKeys))
+	if len(shortURLKeys) == 0 {
+		return records, nil
+	}
+
+	keys, err := json.Marshal(shortURLKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := storage.queryRecords(ctx, `
+	SELECT `+recordColumns+`
+	FROM shortener
+	WHERE short_url_key IN (SELECT jsonb_array_elements_text($1::jsonb));`, string(keys))
+	if err != nil {
+		return nil, err
 	}
 
+	for _, record := range found {
+		records[record.ShortURLKey] = record
+	}
+	return records, nil
+}
+
+func (storage *DBStorage) Write(ctx context.Context, record commontypes.URLRecord) error {
+	errInsert := insertRecord(ctx, storage.db, record)
+
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -89,21 +190,16 @@ func (storage *DBStorage) Write(ctx context.Context, shortURLKey string, fullURL
 	}
 }
 
-func (storage *DBStorage) WriteBatch(ctx context.Context, records []commontypes.BatchRecord) error {
+func (storage *DBStorage) WriteBatch(ctx context.Context, records []commontypes.URLRecord) error {
 	tr, err := storage.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tr.Rollback()
 
-	for _, r := range records {
-		queryInsert := `
-    INSERT INTO shortener (full_url, short_url_key) 
-    VALUES ($1, $2);`
-
-		_, errInsert := tr.ExecContext(ctx, queryInsert, r.FullURL, r.ShortURLKey)
-		if errInsert != nil {
-			tr.Rollback()
-			return errInsert
+	for _, record := range records {
+		if err := insertRecord(ctx, tr, record); err != nil {
+			return err
 		}
 	}
 
@@ -114,3 +210,674 @@ func (storage *DBStorage) WriteBatch(ctx context.Context, records []commontypes.
 		return tr.Commit()
 	}
 }
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// insertRecord stores the whole record, click counters included, so that a link copied
+// from another storage keeps its statistics.
+func insertRecord(ctx context.Context, db execer, record commontypes.URLRecord) error {
+	queryInsert := `
+    INSERT INTO shortener (full_url, short_url_key, created_at, clicks, redirect_type,
+        pass_query, query_precedence, pass_path, utm, device_rules, geo_rules, password_hash,
+        max_clicks, clicks_left, not_before, not_after, user_id, title, notes, tags,
+        variants, variant_clicks, country_clicks, alias) 
+    VALUES ($1, $2, COALESCE($3, now()), $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+        $18, $19, $20, $21, COALESCE($22, '{}'), COALESCE($23, '{}'), $24);`
+
+	utm, err := jsonColumn(record.UTM)
+	if err != nil {
+		return err
+	}
+
+	deviceRules, err := jsonColumn(record.DeviceRules)
+	if err != nil {
+		return err
+	}
+
+	geoRules, err := jsonColumn(record.GeoRules)
+	if err != nil {
+		return err
+	}
+
+	tags, err := jsonColumn(record.Tags)
+	if err != nil {
+		return err
+	}
+
+	variants, err := jsonColumn(record.Variants)
+	if err != nil {
+		return err
+	}
+
+	variantClicks, err := jsonColumn(record.VariantClicks)
+	if err != nil {
+		return err
+	}
+
+	countryClicks, err := jsonColumn(record.CountryClicks)
+	if err != nil {
+		return err
+	}
+
+	_, errInsert := db.ExecContext(ctx, queryInsert,
+		record.FullURL,
+		record.ShortURLKey,
+		nullTime(record.CreatedAt),
+		record.Clicks,
+		record.RedirectType,
+		record.PassQuery,
+		record.QueryPrecedence,
+		record.PassPath,
+		utm,
+		deviceRules,
+		geoRules,
+		record.PasswordHash,
+		record.MaxClicks,
+		record.ClicksLeft,
+		nullTime(record.NotBefore),
+		nullTime(record.NotAfter),
+		record.UserID,
+		record.Title,
+		record.Notes,
+		tags,
+		variants,
+		variantClicks,
+		countryClicks,
+		record.Alias,
+	)
+
+	var pgErr *pgconn.PgError
+	if errors.As(errInsert, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return customerrors.ErrUniqueKeyConstrantViolation
+	}
+	return errInsert
+}
+
+func (storage *DBStorage) RegisterClick(ctx context.Context, shortURLKey string, click commontypes.Click) error {
+	query := `
+	UPDATE shortener
+	SET clicks = clicks + 1,
+		variant_clicks = CASE
+			WHEN $2::text = '' THEN variant_clicks
+			ELSE jsonb_set(variant_clicks, ARRAY[$2::text], to_jsonb(COALESCE((variant_clicks->>$2::text)::bigint, 0) + 1))
+		END,
+		country_clicks = CASE
+			WHEN $3::text = '' THEN country_clicks
+			ELSE jsonb_set(country_clicks, ARRAY[$3::text], to_jsonb(COALESCE((country_clicks->>$3::text)::bigint, 0) + 1))
+		END
+	WHERE short_url_key = $1;`
+
+	_, err := storage.db.ExecContext(ctx, query, shortURLKey, click.Variant, click.Country)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return err
+	}
+}
+
+func (storage *DBStorage) SetVariants(ctx context.Context, shortURLKey string, variants []commontypes.Variant) error {
+	query := `
+	UPDATE shortener
+	SET variants = $2
+	WHERE short_url_key = $1;`
+
+	variantsColumn, err := jsonColumn(variants)
+	if err != nil {
+		return err
+	}
+
+	result, err := storage.db.ExecContext(ctx, query, shortURLKey, variantsColumn)
+	if err == nil {
+		err = checkAffected(result)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return err
+	}
+}
+
+func (storage *DBStorage) ConsumeClick(ctx context.Context, shortURLKey string) (int64, error) {
+	query := `
+	UPDATE shortener
+	SET clicks_left = clicks_left - 1
+	WHERE short_url_key = $1 AND clicks_left > 0
+	RETURNING clicks_left;`
+
+	var clicksLeft int64
+	err := storage.db.QueryRowContext(ctx, query, shortURLKey).Scan(&clicksLeft)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = customerrors.ErrLinkExhausted
+	}
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+		return clicksLeft, err
+	}
+}
+
+func (storage *DBStorage) UpdateURL(ctx context.Context, shortURLKey string, fullURL string, editor string) (commontypes.HistoryEntry, error) {
+	entry := commontypes.HistoryEntry{NewURL: fullURL, Editor: editor}
+
+	tr, err := storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return entry, err
+	}
+	defer tr.Rollback()
+
+	querySelect := `
+	SELECT full_url
+	FROM shortener
+	WHERE short_url_key = $1
+	FOR UPDATE;`
+
+	if err := tr.QueryRowContext(ctx, querySelect, shortURLKey).Scan(&entry.OldURL); err != nil {
+		return entry, err
+	}
+
+	queryUpdate := `
+	UPDATE shortener
+	SET full_url = $2
+	WHERE short_url_key = $1;`
+
+	if _, err := tr.ExecContext(ctx, queryUpdate, shortURLKey, fullURL); err != nil {
+		return entry, err
+	}
+
+	queryInsert := `
+	INSERT INTO shortener_history (short_url_key, old_url, new_url, editor)
+	VALUES ($1, $2, $3, $4)
+	RETURNING changed_at;`
+
+	if err := tr.QueryRowContext(ctx, queryInsert, shortURLKey, entry.OldURL, fullURL, editor).Scan(&entry.ChangedAt); err != nil {
+		return entry, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return entry, ctx.Err()
+	default:
+		return entry, tr.Commit()
+	}
+}
+
+func (storage *DBStorage) ReadHistory(ctx context.Context, shortURLKey string) ([]commontypes.HistoryEntry, error) {
+	query := `
+	SELECT old_url, new_url, changed_at, editor
+	FROM shortener_history
+	WHERE short_url_key = $1
+	ORDER BY id;`
+
+	rows, err := storage.db.QueryContext(ctx, query, shortURLKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []commontypes.HistoryEntry
+	for rows.Next() {
+		var entry commontypes.HistoryEntry
+		if err := rows.Scan(&entry.OldURL, &entry.NewURL, &entry.ChangedAt, &entry.Editor); err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+
+	return history, rows.Err()
+}
+
+func (storage *DBStorage) ListByUser(ctx context.Context, userID string, query commontypes.ListQuery) ([]commontypes.URLRecord, error) {
+	sort := query.Sort
+	if sort == "" {
+		sort = commontypes.SortCreatedDesc
+	}
+
+	column, ok := listSortColumns[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q", sort)
+	}
+
+	direction, comparison := "ASC", ">"
+	if strings.HasPrefix(sort, "-") {
+		direction, comparison = "DESC", "<"
+	}
+
+	conditions := []string{"user_id = $1"}
+	args := []any{userID}
+
+	if query.Tag != "" {
+		args = append(args, query.Tag)
+		conditions = append(conditions, fmt.Sprintf("tags @> jsonb_build_array($%d::text)", len(args)))
+	}
+
+	if query.Search != "" {
+		args = append(args, "%"+escapeLike(query.Search)+"%")
+		conditions = append(conditions, fmt.Sprintf("(full_url ILIKE $%d OR title ILIKE $%d)", len(args), len(args)))
+	}
+
+	if query.After != nil {
+		var value any
+		switch column {
+		case "clicks":
+			value = query.After.Clicks
+		case "title":
+			value = query.After.Title
+		default:
+			value = query.After.CreatedAt
+		}
+		args = append(args, value, query.After.ShortURLKey)
+		conditions = append(conditions, fmt.Sprintf("(%s, short_url_key) %s ($%d, $%d)", column, comparison, len(args)-1, len(args)))
+	}
+
+	statement := `
+	SELECT ` + recordColumns + `
+	FROM shortener
+	WHERE ` + strings.Join(conditions, " AND ") + `
+	ORDER BY ` + column + ` ` + direction + `, short_url_key ` + direction
+
+	if query.Limit > 0 {
+		args = append(args, query.Limit)
+		statement += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	return storage.queryRecords(ctx, statement, args...)
+}
+
+func (storage *DBStorage) List(ctx context.Context, after *commontypes.ListCursor, limit int) ([]commontypes.URLRecord, error) {
+	statement := `SELECT ` + recordColumns + ` FROM shortener`
+	var args []any
+
+	if after != nil {
+		args = append(args, after.CreatedAt, after.ShortURLKey)
+		statement += ` WHERE (created_at, short_url_key) > ($1, $2)`
+	}
+
+	statement += ` ORDER BY created_at, short_url_key`
+
+	if limit > 0 {
+		args = append(args, limit)
+		statement += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	return storage.queryRecords(ctx, statement, args...)
+}
+
+func (storage *DBStorage) Delete(ctx context.Context, shortURLKey string) error {
+	tr, err := storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tr.Rollback()
+
+	result, err := tr.ExecContext(ctx, `DELETE FROM shortener WHERE short_url_key = $1`, shortURLKey)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return customerrors.ErrNotFound
+	}
+
+	if _, err := tr.ExecContext(ctx, `DELETE FROM shortener_history WHERE short_url_key = $1`, shortURLKey); err != nil {
+		return err
+	}
+
+	return tr.Commit()
+}
+
+func (storage *DBStorage) Stats(ctx context.Context) (commontypes.Stats, error) {
+	var stats commontypes.Stats
+	err := storage.db.QueryRowContext(ctx,
+		`SELECT count(*), count(DISTINCT NULLIF(user_id, '')) FROM shortener`,
+	).Scan(&stats.URLs, &stats.Users)
+	return stats, err
+}
+
+func (storage *DBStorage) AddUsage(ctx context.Context, subject string, period string, n int64, limit int64) (int64, error) {
+	if exceedsLimit(0, n, limit) {
+		used, err := storage.ReadUsage(ctx, subject, period)
+		if err != nil {
+			return 0, err
+		}
+		return used, customerrors.ErrQuotaExceeded
+	}
+
+	// The limit is checked by the update itself so that concurrent requests cannot
+	// both take the last links.
+	var used int64
+	err := storage.db.QueryRowContext(ctx, `
+	INSERT INTO shortener_usage (subject, period, used)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (subject, period) DO UPDATE
+	SET used = shortener_usage.used + EXCLUDED.used
+	WHERE $4 <= 0 OR EXCLUDED.used <= 0 OR shortener_usage.used + EXCLUDED.used <= $4
+	RETURNING used`, subject, period, n, limit).Scan(&used)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		used, err = storage.ReadUsage(ctx, subject, period)
+		if err != nil {
+			return 0, err
+		}
+		return used, customerrors.ErrQuotaExceeded
+	}
+
+	return used, err
+}
+
+func (storage *DBStorage) ReadUsage(ctx context.Context, subject string, period string) (int64, error) {
+	var used int64
+	err := storage.db.QueryRowContext(ctx,
+		`SELECT used FROM shortener_usage WHERE subject = $1 AND period = $2`,
+		subject, period,
+	).Scan(&used)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return used, err
+}
+
+const queryCountActive = `
+	SELECT count(*)
+	FROM shortener
+	WHERE user_id = $1
+	    AND (not_after IS NULL OR not_after > $2)
+	    AND (max_clicks = 0 OR clicks_left > 0)`
+
+func (storage *DBStorage) CountActiveURLs(ctx context.Context, userID string, now time.Time) (int64, error) {
+	var count int64
+	err := storage.db.QueryRowContext(ctx, queryCountActive, userID, now).Scan(&count)
+	return count, err
+}
+
+// ReserveActiveURLs keeps the links held by the user as a usage counter, so that every
+// instance sees them. Reservations of a user are serialized by an advisory lock taken
+// for the transaction.
+func (storage *DBStorage) ReserveActiveURLs(ctx context.Context, userID string, n int64, limit int64, now time.Time) error {
+	tr, err := storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tr.Rollback()
+
+	if _, err := tr.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, reservedSubject(userID)); err != nil {
+		return err
+	}
+
+	var reserved int64
+	err = tr.QueryRowContext(ctx,
+		`SELECT used FROM shortener_usage WHERE subject = $1 AND period = $2`,
+		reservedSubject(userID), reservedPeriod,
+	).Scan(&reserved)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if exceedsLimit(0, n, limit) {
+		var count int64
+		if err := tr.QueryRowContext(ctx, queryCountActive, userID, now).Scan(&count); err != nil {
+			return err
+		}
+		if exceedsLimit(reserved+count, n, limit) {
+			return customerrors.ErrQuotaExceeded
+		}
+	}
+
+	_, err = tr.ExecContext(ctx, `
+	INSERT INTO shortener_usage (subject, period, used)
+	VALUES ($1, $2, GREATEST($3::bigint, 0))
+	ON CONFLICT (subject, period) DO UPDATE
+	SET used = GREATEST(shortener_usage.used + $3::bigint, 0)`, reservedSubject(userID), reservedPeriod, n)
+	if err != nil {
+		return err
+	}
+
+	return tr.Commit()
+}
+
+// The active links held by a user are stored next to the quota usage, under a period
+// of their own.
+const reservedPeriod = "reserved"
+
+func reservedSubject(userID string) string {
+	return "active:" + userID
+}
+
+func (storage *DBStorage) WriteAPIKey(ctx context.Context, key commontypes.APIKey) error {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return err
+	}
+
+	_, err = storage.db.ExecContext(ctx, `
+	INSERT INTO shortener_api_keys (id, user_id, name, key_hash, scopes, created_at, revoked_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		key.ID, key.UserID, key.Name, key.Hash, scopes, key.CreatedAt, nullTime(key.RevokedAt),
+	)
+	return err
+}
+
+func (storage *DBStorage) ReadAPIKey(ctx context.Context, hash string) (commontypes.APIKey, error) {
+	row := storage.db.QueryRowContext(ctx, `
+	SELECT `+apiKeyColumns+`
+	FROM shortener_api_keys
+	WHERE key_hash = $1`, hash)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return key, customerrors.ErrNotFound
+	}
+	return key, err
+}
+
+func (storage *DBStorage) ListAPIKeys(ctx context.Context, userID string) ([]commontypes.APIKey, error) {
+	rows, err := storage.db.QueryContext(ctx, `
+	SELECT `+apiKeyColumns+`
+	FROM shortener_api_keys
+	WHERE user_id = $1
+	ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []commontypes.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (storage *DBStorage) RevokeAPIKey(ctx context.Context, userID string, id string, revokedAt time.Time) error {
+	result, err := storage.db.ExecContext(ctx, `
+	UPDATE shortener_api_keys
+	SET revoked_at = COALESCE(revoked_at, $3)
+	WHERE id = $1 AND user_id = $2`, id, userID, revokedAt)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return customerrors.ErrNotFound
+	}
+	return nil
+}
+
+func scanAPIKey(row rowScanner) (commontypes.APIKey, error) {
+	var key commontypes.APIKey
+	var scopes []byte
+	var revokedAt sql.NullTime
+
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Hash, &scopes, &key.CreatedAt, &revokedAt)
+	if err != nil {
+		return key, err
+	}
+
+	key.RevokedAt = revokedAt.Time
+	err = scanJSONColumn(scopes, &key.Scopes)
+	return key, err
+}
+
+func (storage *DBStorage) queryRecords(ctx context.Context, statement string, args ...any) ([]commontypes.URLRecord, error) {
+	rows, err := storage.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []commontypes.URLRecord
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+func scanRecord(row rowScanner) (commontypes.URLRecord, error) {
+	var record commontypes.URLRecord
+	var utm, deviceRules, variants, variantClicks, geoRules, countryClicks, tags []byte
+	var notBefore, notAfter sql.NullTime
+
+	err := row.Scan(
+		&record.ShortURLKey,
+		&record.FullURL,
+		&record.UserID,
+		&record.CreatedAt,
+		&record.Clicks,
+		&record.RedirectType,
+		&record.PassQuery,
+		&record.QueryPrecedence,
+		&record.PassPath,
+		&utm,
+		&deviceRules,
+		&variants,
+		&variantClicks,
+		&geoRules,
+		&countryClicks,
+		&record.PasswordHash,
+		&record.MaxClicks,
+		&record.ClicksLeft,
+		&notBefore,
+		&notAfter,
+		&record.Title,
+		&record.Notes,
+		&tags,
+		&record.Alias,
+	)
+	if err != nil {
+		return record, err
+	}
+
+	record.NotBefore = notBefore.Time
+	record.NotAfter = notAfter.Time
+
+	if err := scanJSONColumn(utm, &record.UTM); err != nil {
+		return record, err
+	}
+
+	if err := scanJSONColumn(deviceRules, &record.DeviceRules); err != nil {
+		return record, err
+	}
+
+	if err := scanJSONColumn(variants, &record.Variants); err != nil {
+		return record, err
+	}
+
+	if err := scanJSONColumn(variantClicks, &record.VariantClicks); err != nil {
+		return record, err
+	}
+
+	if err := scanJSONColumn(geoRules, &record.GeoRules); err != nil {
+		return record, err
+	}
+
+	if err := scanJSONColumn(tags, &record.Tags); err != nil {
+		return record, err
+	}
+
+	err = scanJSONColumn(countryClicks, &record.CountryClicks)
+	return record, err
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// jsonColumn marshals the value for a JSONB column, empty values are stored as NULL.
+func jsonColumn(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch string(data) {
+	case "null", "{}", "[]":
+		return nil, nil
+	default:
+		return string(data), nil
+	}
+}
+
+func scanJSONColumn(data []byte, target any) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, target)
+}
+
+// escapeLike escapes the wildcards of LIKE patterns.
+func escapeLike(str string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(str)
+}
+
+func checkAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// LoadOrStoreAuthSecret keeps the secret in the database, so that all the instances
+// sharing it sign the cookies alike.
+func (storage *DBStorage) LoadOrStoreAuthSecret(ctx context.Context, secret string) (string, error) {
+	_, err := storage.db.ExecContext(ctx, `
+	INSERT INTO shortener_secrets (name, value)
+	VALUES ('auth', $1)
+	ON CONFLICT (name) DO NOTHING;`, secret)
+	if err != nil {
+		return "", err
+	}
+
+	var stored string
+	err = storage.db.QueryRowContext(ctx, `SELECT value FROM shortener_secrets WHERE name = 'auth';`).Scan(&stored)
+	return stored, err
+}
@@ -2,25 +2,61 @@ package storage
 
 import (
 	"context"
-	"errors"
+	"slices"
+	"sync"
+	"time"
 
 	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
 )
 
-type URLStorageMap map[string]string
+type URLStorageMap map[string]commontypes.URLRecord
 
 type InMemoryStorage struct {
-	urlMap URLStorageMap
+	mu      sync.RWMutex
+	urlMap  URLStorageMap
+	created *createdIndex
+	history map[string][]commontypes.HistoryEntry
+	apiKeys map[string]commontypes.APIKey // by hash
+	usage   map[usageKey]int64
+	// reserved are the active links held by users while they are being created.
+	reserved   map[string]int64
+	authSecret string
+}
+
+type usageKey struct {
+	subject string
+	period  string
 }
 
 func NewInMemoryStorage(storageMap URLStorageMap) *InMemoryStorage {
+	records := make([]commontypes.URLRecord, 0, len(storageMap))
+	for _, record := range storageMap {
+		records = append(records, record)
+	}
+
 	return &InMemoryStorage{
-		urlMap: storageMap,
+		urlMap:   storageMap,
+		created:  newCreatedIndex(records),
+		history:  map[string][]commontypes.HistoryEntry{},
+		apiKeys:  map[string]commontypes.APIKey{},
+		usage:    map[usageKey]int64{},
+		reserved: map[string]int64{},
 	}
 }
 
-func (storage *InMemoryStorage) Write(ctx context.Context, shortURLKey string, fullURL string) error {
-	storage.urlMap[shortURLKey] = fullURL
+func (storage *InMemoryStorage) Write(ctx context.Context, record commontypes.URLRecord) error {
+	storage.mu.Lock()
+	_, exists := storage.urlMap[record.ShortURLKey]
+	if !exists {
+		storage.add(newURLRecord(record))
+	}
+	storage.mu.Unlock()
+
+	if exists {
+		return customerrors.ErrUniqueKeyConstrantViolation
+	}
+
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -29,9 +65,20 @@ func (storage *InMemoryStorage) Write(ctx context.Context, shortURLKey string, f
 	}
 }
 
-func (storage *InMemoryStorage) WriteBatch(ctx context.Context, records []commontypes.BatchRecord) error {
+func (storage *InMemoryStorage) WriteBatch(ctx context.Context, records []commontypes.URLRecord) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	// Like a transaction, nothing is written when one of the keys exists.
+	if hasTakenKey(records, func(key string) bool {
+		_, ok := storage.urlMap[key]
+		return ok
+	}) {
+		return customerrors.ErrUniqueKeyConstrantViolation
+	}
+
 	for _, r := range records {
-		storage.urlMap[r.ShortURLKey] = r.FullURL
+		storage.add(newURLRecord(r))
 	}
 
 	select {
@@ -42,22 +89,409 @@ func (storage *InMemoryStorage) WriteBatch(ctx context.Context, records []common
 	}
 }
 
-func (storage *InMemoryStorage) Read(ctx context.Context, shortURLKey string) (string, error) {
-	fullURL, ok := storage.urlMap[shortURLKey]
+func (storage *InMemoryStorage) Read(ctx context.Context, shortURLKey string) (commontypes.URLRecord, error) {
+	storage.mu.RLock()
+	record, ok := storage.urlMap[shortURLKey]
+	storage.mu.RUnlock()
+
+	if !ok {
+		return commontypes.URLRecord{}, customerrors.ErrNotFound
+	}
+
+	select {
+	case <-ctx.Done():
+		return commontypes.URLRecord{}, ctx.Err()
+	default:
+		return record, nil
+	}
+
+}
+
+func (storage *InMemoryStorage) ReadMany(ctx context.Context, shortURLKeys []string) (map[string]commontypes.URLRecord, error) {
+	storage.mu.RLock()
+	records := make(map[string]commontypes.URLRecord, len(shortURLKeys))
+	for _, key := range shortURLKeys {
+		if record, ok := storage.urlMap[key]; ok {
+			records[key] = record
+		}
+	}
+	storage.mu.RUnlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return records, nil
+	}
+}
+
+func (storage *InMemoryStorage) RegisterClick(ctx context.Context, shortURLKey string, click commontypes.Click) error {
+	return storage.update(ctx, shortURLKey, func(record *commontypes.URLRecord) error {
+		record.Clicks++
+		if click.Variant != "" {
+			record.VariantClicks = incrementCounter(record.VariantClicks, click.Variant)
+		}
+		if click.Country != "" {
+			record.CountryClicks = incrementCounter(record.CountryClicks, click.Country)
+		}
+		return nil
+	})
+}
+
+// incrementCounter returns a copy of the counters with the key incremented, the maps
+// of records already handed out by Read must stay untouched.
+func incrementCounter(counters map[string]int64, key string) map[string]int64 {
+	incremented := make(map[string]int64, len(counters)+1)
+	for k, v := range counters {
+		incremented[k] = v
+	}
+	incremented[key]++
+	return incremented
+}
+
+func (storage *InMemoryStorage) SetVariants(ctx context.Context, shortURLKey string, variants []commontypes.Variant) error {
+	return storage.update(ctx, shortURLKey, func(record *commontypes.URLRecord) error {
+		record.Variants = variants
+		return nil
+	})
+}
+
+func (storage *InMemoryStorage) ConsumeClick(ctx context.Context, shortURLKey string) (int64, error) {
+	var clicksLeft int64
+	err := storage.update(ctx, shortURLKey, func(record *commontypes.URLRecord) error {
+		if record.ClicksLeft <= 0 {
+			return customerrors.ErrLinkExhausted
+		}
+		record.ClicksLeft--
+		clicksLeft = record.ClicksLeft
+		return nil
+	})
+	return clicksLeft, err
+}
+
+func (storage *InMemoryStorage) UpdateURL(ctx context.Context, shortURLKey string, fullURL string, editor string) (commontypes.HistoryEntry, error) {
+	var entry commontypes.HistoryEntry
+	err := storage.update(ctx, shortURLKey, func(record *commontypes.URLRecord) error {
+		entry = commontypes.HistoryEntry{
+			OldURL:    record.FullURL,
+			NewURL:    fullURL,
+			ChangedAt: time.Now(),
+			Editor:    editor,
+		}
+		record.FullURL = fullURL
+		storage.history[shortURLKey] = append(storage.history[shortURLKey], entry)
+		return nil
+	})
+	return entry, err
+}
+
+func (storage *InMemoryStorage) ReadHistory(ctx context.Context, shortURLKey string) ([]commontypes.HistoryEntry, error) {
+	storage.mu.RLock()
+	history := slices.Clone(storage.history[shortURLKey])
+	storage.mu.RUnlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return history, nil
+	}
+}
+
+func (storage *InMemoryStorage) ListByUser(ctx context.Context, userID string, query commontypes.ListQuery) ([]commontypes.URLRecord, error) {
+	var records []commontypes.URLRecord
+
+	storage.mu.RLock()
+	for _, record := range storage.urlMap {
+		if record.UserID == userID {
+			records = append(records, record)
+		}
+	}
+	storage.mu.RUnlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return listRecords(records, query), nil
+	}
+}
+
+func (storage *InMemoryStorage) List(ctx context.Context, after *commontypes.ListCursor, limit int) ([]commontypes.URLRecord, error) {
+	storage.mu.RLock()
+	keys := storage.created.after(after, limit)
+	records := make([]commontypes.URLRecord, 0, len(keys))
+	for _, key := range keys {
+		records = append(records, storage.urlMap[key])
+	}
+	storage.mu.RUnlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return records, nil
+	}
+}
+
+func (storage *InMemoryStorage) Delete(ctx context.Context, shortURLKey string) error {
+	storage.mu.Lock()
+	record, ok := storage.urlMap[shortURLKey]
+	if ok {
+		delete(storage.urlMap, shortURLKey)
+		delete(storage.history, shortURLKey)
+		storage.created.remove(record.CreatedAt, shortURLKey)
+	}
+	storage.mu.Unlock()
 
 	if !ok {
-		return "", errors.New("not found")
+		return customerrors.ErrNotFound
 	}
 
 	select {
 	case <-ctx.Done():
-		return "", ctx.Err()
+		return ctx.Err()
 	default:
-		return fullURL, nil
+		return nil
+	}
+}
+
+func (storage *InMemoryStorage) Stats(ctx context.Context) (commontypes.Stats, error) {
+	users := map[string]struct{}{}
+
+	storage.mu.RLock()
+	for _, record := range storage.urlMap {
+		if record.UserID != "" {
+			users[record.UserID] = struct{}{}
+		}
 	}
+	stats := commontypes.Stats{URLs: int64(len(storage.urlMap)), Users: int64(len(users))}
+	storage.mu.RUnlock()
 
+	select {
+	case <-ctx.Done():
+		return commontypes.Stats{}, ctx.Err()
+	default:
+		return stats, nil
+	}
+}
+
+func (storage *InMemoryStorage) AddUsage(ctx context.Context, subject string, period string, n int64, limit int64) (int64, error) {
+	key := usageKey{subject, period}
+
+	storage.mu.Lock()
+	used := storage.usage[key]
+	exceeded := exceedsLimit(used, n, limit)
+	if !exceeded {
+		used += n
+		storage.usage[key] = used
+	}
+	storage.mu.Unlock()
+
+	if exceeded {
+		return used, customerrors.ErrQuotaExceeded
+	}
+
+	select {
+	case <-ctx.Done():
+		return used, ctx.Err()
+	default:
+		return used, nil
+	}
+}
+
+func (storage *InMemoryStorage) ReadUsage(ctx context.Context, subject string, period string) (int64, error) {
+	storage.mu.RLock()
+	used := storage.usage[usageKey{subject, period}]
+	storage.mu.RUnlock()
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+		return used, nil
+	}
+}
+
+func (storage *InMemoryStorage) CountActiveURLs(ctx context.Context, userID string, now time.Time) (int64, error) {
+	storage.mu.RLock()
+	count := storage.countActive(userID, now)
+	storage.mu.RUnlock()
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+		return count, nil
+	}
+}
+
+func (storage *InMemoryStorage) ReserveActiveURLs(ctx context.Context, userID string, n int64, limit int64, now time.Time) error {
+	storage.mu.Lock()
+	used := storage.reserved[userID]
+	if n > 0 && limit > 0 {
+		used += storage.countActive(userID, now)
+	}
+	exceeded := exceedsLimit(used, n, limit)
+	if !exceeded {
+		addReserved(storage.reserved, userID, n)
+	}
+	storage.mu.Unlock()
+
+	if exceeded {
+		return customerrors.ErrQuotaExceeded
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return nil
+	}
+}
+
+func (storage *InMemoryStorage) countActive(userID string, now time.Time) int64 {
+	var count int64
+	for _, record := range storage.urlMap {
+		if record.UserID == userID && isActive(record, now) {
+			count++
+		}
+	}
+	return count
+}
+
+func (storage *InMemoryStorage) WriteAPIKey(ctx context.Context, key commontypes.APIKey) error {
+	storage.mu.Lock()
+	storage.apiKeys[key.Hash] = key
+	storage.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return nil
+	}
+}
+
+func (storage *InMemoryStorage) ReadAPIKey(ctx context.Context, hash string) (commontypes.APIKey, error) {
+	storage.mu.RLock()
+	key, ok := storage.apiKeys[hash]
+	storage.mu.RUnlock()
+
+	if !ok {
+		return commontypes.APIKey{}, customerrors.ErrNotFound
+	}
+
+	select {
+	case <-ctx.Done():
+		return commontypes.APIKey{}, ctx.Err()
+	default:
+		return key, nil
+	}
+}
+
+func (storage *InMemoryStorage) ListAPIKeys(ctx context.Context, userID string) ([]commontypes.APIKey, error) {
+	var keys []commontypes.APIKey
+
+	storage.mu.RLock()
+	for _, key := range storage.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	storage.mu.RUnlock()
+
+	sortAPIKeys(keys)
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return keys, nil
+	}
+}
+
+func (storage *InMemoryStorage) RevokeAPIKey(ctx context.Context, userID string, id string, revokedAt time.Time) error {
+	found := false
+
+	storage.mu.Lock()
+	for hash, key := range storage.apiKeys {
+		if key.ID == id && key.UserID == userID {
+			if key.RevokedAt.IsZero() {
+				key.RevokedAt = revokedAt
+				storage.apiKeys[hash] = key
+			}
+			found = true
+		}
+	}
+	storage.mu.Unlock()
+
+	if !found {
+		return customerrors.ErrNotFound
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return nil
+	}
+}
+
+// add stores a new record, the caller holds the lock.
+func (storage *InMemoryStorage) add(record commontypes.URLRecord) {
+	storage.urlMap[record.ShortURLKey] = record
+	storage.created.insert(record.CreatedAt, record.ShortURLKey)
+}
+
+// update applies the change to the record under the lock, the record is kept as is
+// when apply fails.
+func (storage *InMemoryStorage) update(ctx context.Context, shortURLKey string, apply func(record *commontypes.URLRecord) error) error {
+	storage.mu.Lock()
+	record, ok := storage.urlMap[shortURLKey]
+	var err error
+	if ok {
+		if err = apply(&record); err == nil {
+			storage.urlMap[shortURLKey] = record
+		}
+	}
+	storage.mu.Unlock()
+
+	if !ok {
+		return customerrors.ErrNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return nil
+	}
 }
 
 func (storage *InMemoryStorage) GetStorageSize() int {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
 	return len(storage.urlMap)
 }
+
+func newURLRecord(record commontypes.URLRecord) commontypes.URLRecord {
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	return record
+}
+
+func (storage *InMemoryStorage) LoadOrStoreAuthSecret(ctx context.Context, secret string) (string, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	if storage.authSecret == "" {
+		storage.authSecret = secret
+	}
+	return storage.authSecret, nil
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+)
+
+// listRecords filters, sorts and pages the records the way DBStorage does in SQL.
+func listRecords(records []commontypes.URLRecord, query commontypes.ListQuery) []commontypes.URLRecord {
+	search := strings.ToLower(query.Search)
+
+	matching := make([]commontypes.URLRecord, 0, len(records))
+	for _, record := range records {
+		if query.Tag != "" && !slices.Contains(record.Tags, query.Tag) {
+			continue
+		}
+		if search != "" &&
+			!strings.Contains(strings.ToLower(record.FullURL), search) &&
+			!strings.Contains(strings.ToLower(record.Title), search) {
+			continue
+		}
+		if query.After != nil && compareToCursor(record, *query.After, query.Sort) <= 0 {
+			continue
+		}
+		matching = append(matching, record)
+	}
+
+	slices.SortFunc(matching, func(a, b commontypes.URLRecord) int {
+		return compareToCursor(a, b.ListCursor(), query.Sort)
+	})
+
+	if query.Limit > 0 && len(matching) > query.Limit {
+		matching = matching[:query.Limit]
+	}
+
+	return matching
+}
+
+// isActive tells whether the link still redirects, the way DBStorage counts active links.
+func isActive(record commontypes.URLRecord, now time.Time) bool {
+	if !record.NotAfter.IsZero() && !record.NotAfter.After(now) {
+		return false
+	}
+	return record.MaxClicks == 0 || record.ClicksLeft > 0
+}
+
+// exceedsLimit tells whether adding n to the usage goes over the limit.
+func exceedsLimit(used int64, n int64, limit int64) bool {
+	return n > 0 && limit > 0 && used+n > limit
+}
+
+// addReserved adds n to the active links held by the user, forgetting users who hold
+// none.
+func addReserved(reserved map[string]int64, userID string, n int64) {
+	if held := reserved[userID] + n; held > 0 {
+		reserved[userID] = held
+	} else {
+		delete(reserved, userID)
+	}
+}
+
+// sortAPIKeys puts the keys in creation order like DBStorage does.
+func sortAPIKeys(keys []commontypes.APIKey) {
+	slices.SortFunc(keys, func(a, b commontypes.APIKey) int {
+		if result := a.CreatedAt.Compare(b.CreatedAt); result != 0 {
+			return result
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
+
+// compareToCursor tells whether the record comes before (-1) or after (1) the cursor
+// in the sort order.
+func compareToCursor(record commontypes.URLRecord, cursor commontypes.ListCursor, sort string) int {
+	var result int
+
+	switch strings.TrimPrefix(sort, "-") {
+	case commontypes.SortClicksAsc:
+		result = cmp.Compare(record.Clicks, cursor.Clicks)
+	case commontypes.SortTitleAsc:
+		result = cmp.Compare(record.Title, cursor.Title)
+	default:
+		result = record.CreatedAt.Compare(cursor.CreatedAt)
+	}
+
+	if result == 0 {
+		result = cmp.Compare(record.ShortURLKey, cursor.ShortURLKey)
+	}
+
+	if strings.HasPrefix(sort, "-") || sort == "" {
+		return -result
+	}
+	return result
+}
+
+// hasTakenKey tells whether a key of the batch is taken, by a stored link or by another
+// record of the batch.
+func hasTakenKey(records []commontypes.URLRecord, stored func(key string) bool) bool {
+	keys := make(map[string]bool, len(records))
+	for _, r := range records {
+		if keys[r.ShortURLKey] || stored(r.ShortURLKey) {
+			return true
+		}
+		keys[r.ShortURLKey] = true
+	}
+	return false
+}
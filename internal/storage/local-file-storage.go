@@ -5,37 +5,86 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	customerrors "github.com/with0p/golang-url-shortener.git/internal/custom-errors"
 	"github.com/with0p/golang-url-shortener.git/internal/logger"
 	localfile "github.com/with0p/golang-url-shortener.git/internal/storage/local-file"
 )
 
+// LocalFileStorage keeps records as JSON lines. A record is updated by appending its
+// new version, the last line with a given short URL wins.
 type LocalFileStorage struct {
+	mu       sync.Mutex
 	filePath string
+	// created and listed are built from the file by the first List and kept up to date
+	// by writes, so that paging does not read the whole file for every page.
+	created *createdIndex
+	listed  map[string]commontypes.URLRecord
+	// reserved are the active links held by users while they are being created.
+	reserved map[string]int64
+	// usage is read from its file by the first use and kept up to date by AddUsage,
+	// usageLines counts the lines of the file to tell when to compact it.
+	usage      map[usageKey]*localfile.LocalFileUsage
+	usageLines int
 }
 
 func NewLocalFileStorage(filePath string) (*LocalFileStorage, error) {
-	return &LocalFileStorage{filePath: filePath}, nil
+	return &LocalFileStorage{filePath: filePath, reserved: map[string]int64{}}, nil
 }
 
-func (storage *LocalFileStorage) Write(ctx context.Context, shortURLKey string, fullURL string) error {
-	file, err := os.OpenFile(storage.filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+func (storage *LocalFileStorage) Write(ctx context.Context, record commontypes.URLRecord) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	fileData, err := storage.readAll()
 	if err != nil {
-		logger.LogError(err)
 		return err
 	}
-	defer file.Close()
 
-	data, err := json.Marshal(localfile.NewLocalFileRecord(shortURLKey, fullURL))
+	if _, ok := fileData[record.ShortURLKey]; ok {
+		return customerrors.ErrUniqueKeyConstrantViolation
+	}
+
+	err = storage.appendRecords(localfile.NewLocalFileRecord(record))
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return err
+	}
+}
+
+func (storage *LocalFileStorage) WriteBatch(ctx context.Context, records []commontypes.URLRecord) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	fileData, err := storage.readAll()
 	if err != nil {
-		logger.LogError(err)
 		return err
 	}
-	data = append(data, '\n')
 
-	_, err = file.Write(data)
+	// Like a transaction, nothing is written when one of the keys exists.
+	if hasTakenKey(records, func(key string) bool {
+		_, ok := fileData[key]
+		return ok
+	}) {
+		return customerrors.ErrUniqueKeyConstrantViolation
+	}
+
+	recordsToWrite := make([]*localfile.LocalFileRecord, len(records))
+	for i, r := range records {
+		recordsToWrite[i] = localfile.NewLocalFileRecord(r)
+	}
+
+	err = storage.appendRecords(recordsToWrite...)
 
 	select {
 	case <-ctx.Done():
@@ -45,27 +94,429 @@ func (storage *LocalFileStorage) Write(ctx context.Context, shortURLKey string,
 	}
 }
 
-func (storage *LocalFileStorage) WriteBatch(ctx context.Context, records []commontypes.BatchRecord) error {
-	file, err := os.OpenFile(storage.filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+func (storage *LocalFileStorage) Read(ctx context.Context, shortURLKey string) (commontypes.URLRecord, error) {
+	storage.mu.Lock()
+	fileData, err := storage.readAll()
+	storage.mu.Unlock()
+
+	if err != nil {
+		return commontypes.URLRecord{}, err
+	}
+
+	record, ok := fileData[shortURLKey]
+
+	if !ok {
+		return commontypes.URLRecord{}, customerrors.ErrNotFound
+	}
+
+	select {
+	case <-ctx.Done():
+		return commontypes.URLRecord{}, ctx.Err()
+	default:
+		return record.ToURLRecord(), nil
+	}
+}
+
+// ReadMany reads the file once for all the keys.
+func (storage *LocalFileStorage) ReadMany(ctx context.Context, shortURLKeys []string) (map[string]commontypes.URLRecord, error) {
+	storage.mu.Lock()
+	fileData, err := storage.readAll()
+	storage.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[string]commontypes.URLRecord, len(shortURLKeys))
+	for _, key := range shortURLKeys {
+		if record, ok := fileData[key]; ok {
+			records[key] = record.ToURLRecord()
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return records, nil
+	}
+}
+
+func (storage *LocalFileStorage) RegisterClick(ctx context.Context, shortURLKey string, click commontypes.Click) error {
+	return storage.update(ctx, shortURLKey, func(record *localfile.LocalFileRecord) error {
+		record.Clicks++
+		if click.Variant != "" {
+			if record.VariantClicks == nil {
+				record.VariantClicks = map[string]int64{}
+			}
+			record.VariantClicks[click.Variant]++
+		}
+		if click.Country != "" {
+			if record.CountryClicks == nil {
+				record.CountryClicks = map[string]int64{}
+			}
+			record.CountryClicks[click.Country]++
+		}
+		return nil
+	})
+}
+
+func (storage *LocalFileStorage) SetVariants(ctx context.Context, shortURLKey string, variants []commontypes.Variant) error {
+	return storage.update(ctx, shortURLKey, func(record *localfile.LocalFileRecord) error {
+		record.Variants = variants
+		return nil
+	})
+}
+
+func (storage *LocalFileStorage) ConsumeClick(ctx context.Context, shortURLKey string) (int64, error) {
+	var clicksLeft int64
+	err := storage.update(ctx, shortURLKey, func(record *localfile.LocalFileRecord) error {
+		if record.ClicksLeft <= 0 {
+			return customerrors.ErrLinkExhausted
+		}
+		record.ClicksLeft--
+		clicksLeft = record.ClicksLeft
+		return nil
+	})
+	return clicksLeft, err
+}
+
+func (storage *LocalFileStorage) UpdateURL(ctx context.Context, shortURLKey string, fullURL string, editor string) (commontypes.HistoryEntry, error) {
+	var entry commontypes.HistoryEntry
+	err := storage.update(ctx, shortURLKey, func(record *localfile.LocalFileRecord) error {
+		entry = commontypes.HistoryEntry{
+			OldURL:    record.OriginalURL,
+			NewURL:    fullURL,
+			ChangedAt: time.Now(),
+			Editor:    editor,
+		}
+		record.OriginalURL = fullURL
+		record.History = append(record.History, entry)
+		return nil
+	})
+	return entry, err
+}
+
+func (storage *LocalFileStorage) ReadHistory(ctx context.Context, shortURLKey string) ([]commontypes.HistoryEntry, error) {
+	storage.mu.Lock()
+	fileData, err := storage.readAll()
+	storage.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var history []commontypes.HistoryEntry
+	if record, ok := fileData[shortURLKey]; ok {
+		history = record.History
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return history, nil
+	}
+}
+
+func (storage *LocalFileStorage) ListByUser(ctx context.Context, userID string, query commontypes.ListQuery) ([]commontypes.URLRecord, error) {
+	storage.mu.Lock()
+	fileData, err := storage.readAll()
+	storage.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var records []commontypes.URLRecord
+	for _, record := range fileData {
+		if record.UserID == userID {
+			records = append(records, record.ToURLRecord())
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return listRecords(records, query), nil
+	}
+}
+
+func (storage *LocalFileStorage) List(ctx context.Context, after *commontypes.ListCursor, limit int) ([]commontypes.URLRecord, error) {
+	storage.mu.Lock()
+	if storage.created == nil {
+		fileData, err := storage.readAll()
+		if err != nil {
+			storage.mu.Unlock()
+			return nil, err
+		}
+
+		records := make([]commontypes.URLRecord, 0, len(fileData))
+		storage.listed = make(map[string]commontypes.URLRecord, len(fileData))
+		for _, record := range fileData {
+			records = append(records, record.ToURLRecord())
+			storage.listed[record.ShortURL] = record.ToURLRecord()
+		}
+		storage.created = newCreatedIndex(records)
+	}
+
+	keys := storage.created.after(after, limit)
+	records := make([]commontypes.URLRecord, 0, len(keys))
+	for _, key := range keys {
+		if record, ok := storage.listed[key]; ok {
+			records = append(records, record)
+		}
+	}
+	storage.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return records, nil
+	}
+}
+
+// Delete appends a tombstone of the record, it hides every previous line of the record.
+func (storage *LocalFileStorage) Delete(ctx context.Context, shortURLKey string) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	fileData, err := storage.readAll()
 	if err != nil {
-		logger.LogError(err)
 		return err
 	}
-	defer file.Close()
 
-	var dataToWrite []byte
+	record, ok := fileData[shortURLKey]
+	if !ok {
+		return customerrors.ErrNotFound
+	}
 
-	for _, r := range records {
-		data, err := json.Marshal(localfile.NewLocalFileRecord(r.ShortURLKey, r.FullURL))
+	err = storage.appendRecords(&localfile.LocalFileRecord{ShortURL: shortURLKey, Deleted: true})
+	if err == nil && storage.created != nil {
+		storage.created.remove(record.CreatedAt, shortURLKey)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return err
+	}
+}
+
+func (storage *LocalFileStorage) Stats(ctx context.Context) (commontypes.Stats, error) {
+	storage.mu.Lock()
+	fileData, err := storage.readAll()
+	storage.mu.Unlock()
+
+	if err != nil {
+		return commontypes.Stats{}, err
+	}
+
+	users := map[string]struct{}{}
+	for _, record := range fileData {
+		if record.UserID != "" {
+			users[record.UserID] = struct{}{}
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return commontypes.Stats{}, ctx.Err()
+	default:
+		return commontypes.Stats{URLs: int64(len(fileData)), Users: int64(len(users))}, nil
+	}
+}
+
+func (storage *LocalFileStorage) AddUsage(ctx context.Context, subject string, period string, n int64, limit int64) (int64, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	usage, err := storage.readUsage()
+	if err != nil {
+		return 0, err
+	}
+
+	entry := usage[usageKey{subject, period}]
+	if entry == nil {
+		entry = &localfile.LocalFileUsage{Subject: subject, Period: period}
+	}
+
+	if exceedsLimit(entry.Used, n, limit) {
+		return entry.Used, customerrors.ErrQuotaExceeded
+	}
+
+	updated := *entry
+	updated.Used += n
+	if err := appendJSONLine(storage.usagePath(), &updated); err != nil {
+		return entry.Used, err
+	}
+	usage[usageKey{subject, period}] = &updated
+	storage.usageLines++
+
+	if needsCompaction(storage.usageLines, len(usage)) {
+		if err := storage.compactUsage(); err != nil {
+			logger.LogError(err)
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return updated.Used, ctx.Err()
+	default:
+		return updated.Used, nil
+	}
+}
+
+func (storage *LocalFileStorage) ReadUsage(ctx context.Context, subject string, period string) (int64, error) {
+	storage.mu.Lock()
+	usage, err := storage.readUsage()
+	storage.mu.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+
+	var used int64
+	if entry, ok := usage[usageKey{subject, period}]; ok {
+		used = entry.Used
+	}
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+		return used, nil
+	}
+}
+
+func (storage *LocalFileStorage) CountActiveURLs(ctx context.Context, userID string, now time.Time) (int64, error) {
+	storage.mu.Lock()
+	fileData, err := storage.readAll()
+	storage.mu.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+
+	count := countActive(fileData, userID, now)
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+		return count, nil
+	}
+}
+
+func (storage *LocalFileStorage) ReserveActiveURLs(ctx context.Context, userID string, n int64, limit int64, now time.Time) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	used := storage.reserved[userID]
+	if n > 0 && limit > 0 {
+		fileData, err := storage.readAll()
 		if err != nil {
+			return err
+		}
+		used += countActive(fileData, userID, now)
+	}
+
+	if exceedsLimit(used, n, limit) {
+		return customerrors.ErrQuotaExceeded
+	}
+
+	addReserved(storage.reserved, userID, n)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return nil
+	}
+}
+
+func countActive(fileData map[string]*localfile.LocalFileRecord, userID string, now time.Time) int64 {
+	var count int64
+	for _, record := range fileData {
+		if record.UserID == userID && isActive(record.ToURLRecord(), now) {
+			count++
+		}
+	}
+	return count
+}
+
+// usagePath is the file of the usage counters, the last line of a subject and period wins.
+func (storage *LocalFileStorage) usagePath() string {
+	return storage.filePath + ".usage"
+}
+
+// readUsage returns the usage counters, the file is only read the first time.
+func (storage *LocalFileStorage) readUsage() (map[usageKey]*localfile.LocalFileUsage, error) {
+	if storage.usage != nil {
+		return storage.usage, nil
+	}
+
+	usage := map[usageKey]*localfile.LocalFileUsage{}
+	lines := 0
+	err := readJSONLines(storage.usagePath(), func(entry *localfile.LocalFileUsage) {
+		usage[usageKey{entry.Subject, entry.Period}] = entry
+		lines++
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	storage.usage, storage.usageLines = usage, lines
+	if needsCompaction(lines, len(usage)) {
+		if err := storage.compactUsage(); err != nil {
 			logger.LogError(err)
+		}
+	}
+
+	return usage, nil
+}
+
+// compactUsage replaces the usage file with one line per counter. The counters given
+// back to zero and those of the past days, which are not read anymore, are left out.
+func (storage *LocalFileStorage) compactUsage() error {
+	today := time.Now().UTC().Format(time.DateOnly)
+
+	keys := make([]usageKey, 0, len(storage.usage))
+	for key, entry := range storage.usage {
+		if entry.Used == 0 || isPastDay(key.period, today) {
+			delete(storage.usage, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b usageKey) int {
+		return strings.Compare(a.subject+"\n"+a.period, b.subject+"\n"+b.period)
+	})
+
+	var dataToWrite []byte
+	for _, key := range keys {
+		data, err := json.Marshal(storage.usage[key])
+		if err != nil {
 			return err
 		}
-		data = append(data, '\n')
 		dataToWrite = append(dataToWrite, data...)
+		dataToWrite = append(dataToWrite, '\n')
+	}
+
+	if err := replaceFile(storage.usagePath(), dataToWrite); err != nil {
+		return err
 	}
+	storage.usageLines = len(keys)
+	return nil
+}
 
-	_, err = file.Write(dataToWrite)
+func (storage *LocalFileStorage) WriteAPIKey(ctx context.Context, key commontypes.APIKey) error {
+	storage.mu.Lock()
+	err := appendJSONLine(storage.apiKeysPath(), localfile.NewLocalFileAPIKey(key))
+	storage.mu.Unlock()
 
 	select {
 	case <-ctx.Done():
@@ -75,52 +526,350 @@ func (storage *LocalFileStorage) WriteBatch(ctx context.Context, records []commo
 	}
 }
 
-func (storage *LocalFileStorage) Read(ctx context.Context, shortURLKey string) (string, error) {
-	file, err := os.OpenFile(storage.filePath, os.O_RDONLY|os.O_CREATE, 0666)
+func (storage *LocalFileStorage) ReadAPIKey(ctx context.Context, hash string) (commontypes.APIKey, error) {
+	storage.mu.Lock()
+	keys, err := storage.readAPIKeys()
+	storage.mu.Unlock()
+
 	if err != nil {
-		logger.LogError(err)
-		return "", err
+		return commontypes.APIKey{}, err
+	}
+
+	for _, key := range keys {
+		if key.Hash == hash {
+			select {
+			case <-ctx.Done():
+				return commontypes.APIKey{}, ctx.Err()
+			default:
+				return key.ToAPIKey(), nil
+			}
+		}
 	}
 
-	fileData, err := readFileToMap(file)
+	return commontypes.APIKey{}, customerrors.ErrNotFound
+}
+
+func (storage *LocalFileStorage) ListAPIKeys(ctx context.Context, userID string) ([]commontypes.APIKey, error) {
+	storage.mu.Lock()
+	fileKeys, err := storage.readAPIKeys()
+	storage.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []commontypes.APIKey
+	for _, key := range fileKeys {
+		if key.UserID == userID {
+			keys = append(keys, key.ToAPIKey())
+		}
+	}
+
+	sortAPIKeys(keys)
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return keys, nil
+	}
+}
+
+func (storage *LocalFileStorage) RevokeAPIKey(ctx context.Context, userID string, id string, revokedAt time.Time) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	keys, err := storage.readAPIKeys()
+	if err != nil {
+		return err
+	}
+
+	key, ok := keys[id]
+	if !ok || key.UserID != userID {
+		return customerrors.ErrNotFound
+	}
+
+	if key.RevokedAt == nil {
+		key.RevokedAt = &revokedAt
+		err = appendJSONLine(storage.apiKeysPath(), key)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return err
+	}
+}
+
+// apiKeysPath is the file of the API keys next to the records, the last line with a
+// given key id wins.
+func (storage *LocalFileStorage) apiKeysPath() string {
+	return storage.filePath + ".api-keys"
+}
+
+func (storage *LocalFileStorage) readAPIKeys() (map[string]*localfile.LocalFileAPIKey, error) {
+	keys := map[string]*localfile.LocalFileAPIKey{}
+	err := readJSONLines(storage.apiKeysPath(), func(key *localfile.LocalFileAPIKey) {
+		keys[key.ID] = key
+	})
+	return keys, err
+}
+
+// LoadOrStoreAuthSecret keeps the secret in a file of its own next to the records,
+// readable by the owner only.
+func (storage *LocalFileStorage) LoadOrStoreAuthSecret(ctx context.Context, secret string) (string, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	path := storage.filePath + ".secret"
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if stored := strings.TrimSpace(string(data)); stored != "" {
+			return stored, nil
+		}
+		return "", fmt.Errorf("%s is empty", path)
+	}
 	if err != nil {
-		logger.LogError(err)
 		return "", err
 	}
 	defer file.Close()
 
-	fullURL, ok := fileData[shortURLKey]
+	if _, err := file.WriteString(secret); err != nil {
+		return "", err
+	}
+	return secret, file.Sync()
+}
+
+// update appends the changed record, nothing is written when apply fails.
+func (storage *LocalFileStorage) update(ctx context.Context, shortURLKey string, apply func(record *localfile.LocalFileRecord) error) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	fileData, err := storage.readAll()
+	if err != nil {
+		return err
+	}
 
+	record, ok := fileData[shortURLKey]
 	if !ok {
-		return "", errors.New("not found")
+		return customerrors.ErrNotFound
+	}
+
+	if err := apply(record); err != nil {
+		return err
 	}
+	err = storage.appendRecords(record)
 
 	select {
 	case <-ctx.Done():
-		return "", ctx.Err()
+		return ctx.Err()
 	default:
-		return fullURL, nil
+		return err
 	}
 }
 
-func readFileToMap(file *os.File) (map[string]string, error) {
-	fileData := map[string]string{}
+func (storage *LocalFileStorage) readAll() (map[string]*localfile.LocalFileRecord, error) {
+	file, err := os.OpenFile(storage.filePath, os.O_RDONLY|os.O_CREATE, 0666)
+	if err != nil {
+		logger.LogError(err)
+		return nil, err
+	}
+	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	fileData, lines, err := readFileToMap(file)
+	if err != nil {
+		logger.LogError(err)
+		return nil, err
+	}
+
+	if needsCompaction(lines, len(fileData)) {
+		if err := storage.compact(fileData); err != nil {
+			logger.LogError(err)
+		}
+	}
+
+	return fileData, nil
+}
+
+// Every click and update appends a line, the file is rewritten with the live records
+// only once most of its lines are outdated.
+const (
+	compactionMinLines = 1000
+	compactionRatio    = 4
+)
+
+func needsCompaction(lines int, records int) bool {
+	return lines >= compactionMinLines && lines > compactionRatio*records
+}
+
+// compact replaces the file with one line per record.
+func (storage *LocalFileStorage) compact(fileData map[string]*localfile.LocalFileRecord) error {
+	keys := make([]string, 0, len(fileData))
+	for key := range fileData {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var dataToWrite []byte
+	for _, key := range keys {
+		data, err := json.Marshal(fileData[key])
+		if err != nil {
+			return err
+		}
+		dataToWrite = append(dataToWrite, data...)
+		dataToWrite = append(dataToWrite, '\n')
+	}
+
+	return replaceFile(storage.filePath, dataToWrite)
+}
+
+// isPastDay tells whether the period is a day before today, other periods never pass.
+func isPastDay(period string, today string) bool {
+	if _, err := time.Parse(time.DateOnly, period); err != nil {
+		return false
+	}
+	return period < today
+}
+
+// replaceFile writes the data aside and renames it over the file, so that a failure
+// leaves the old file in place.
+func replaceFile(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0666); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+func (storage *LocalFileStorage) appendRecords(records ...*localfile.LocalFileRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	file, err := os.OpenFile(storage.filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		logger.LogError(err)
+		return err
+	}
+	defer file.Close()
+
+	var dataToWrite []byte
+
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			logger.LogError(err)
+			return err
+		}
+		data = append(data, '\n')
+		dataToWrite = append(dataToWrite, data...)
+	}
+
+	if _, err = file.Write(dataToWrite); err != nil {
+		return err
+	}
+
+	// Updated records keep their creation time, they are already in the index.
+	if storage.created != nil {
+		for _, r := range records {
+			if r.Deleted {
+				delete(storage.listed, r.ShortURL)
+				continue
+			}
+			storage.created.insert(r.CreatedAt, r.ShortURL)
+			storage.listed[r.ShortURL] = r.ToURLRecord()
+		}
+	}
+
+	return nil
+}
+
+func appendJSONLine(path string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		logger.LogError(err)
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		logger.LogError(err)
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+func readJSONLines[T any](path string, apply func(value *T)) error {
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0666)
+	if err != nil {
+		logger.LogError(err)
+		return err
+	}
+	defer file.Close()
+
+	scanner := newLineScanner(file)
+	for scanner.Scan() {
+		value := new(T)
+		if err := json.Unmarshal(scanner.Bytes(), value); err != nil {
+			logger.LogError(err)
+			return err
+		}
+		apply(value)
+	}
+
+	return scanner.Err()
+}
+
+// readFileToMap returns the records of the file and the number of lines they were read
+// from.
+func readFileToMap(file *os.File) (map[string]*localfile.LocalFileRecord, int, error) {
+	fileData := map[string]*localfile.LocalFileRecord{}
+	lines := 0
+
+	scanner := newLineScanner(file)
 
 	for scanner.Scan() {
 		data := scanner.Bytes()
+		lines++
 
-		record := localfile.LocalFileRecord{}
+		record := &localfile.LocalFileRecord{}
 
-		err := json.Unmarshal(data, &record)
+		err := json.Unmarshal(data, record)
 		if err != nil {
 			logger.LogError(err)
-			return nil, err
+			return nil, 0, err
 		}
 
-		fileData[record.ShortURL] = record.OriginalURL
+		if record.Deleted {
+			delete(fileData, record.ShortURL)
+			continue
+		}
+
+		fileData[record.ShortURL] = record
 	}
 
-	return fileData, nil
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return fileData, lines, nil
+}
+
+// maxLineSize bounds a line of the files. Records with many variants or rules are
+// well over the 64 KB bufio.Scanner reads by default.
+const maxLineSize = 16 << 20
+
+func newLineScanner(file *os.File) *bufio.Scanner {
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return scanner
 }
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+	localfile "github.com/with0p/golang-url-shortener.git/internal/storage/local-file"
+)
+
+func TestLocalFileStorageCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+
+	// Every click of a link appends a new version of it.
+	var lines []byte
+	record := localfile.NewLocalFileRecord(commontypes.URLRecord{ShortURLKey: "a0c7ecc8", FullURL: "https://practicum.yandex.kz/"})
+	for i := 0; i < 2*compactionMinLines; i++ {
+		record.Clicks++
+		data, err := json.Marshal(record)
+		require.Nil(t, err)
+		lines = append(append(lines, data...), '\n')
+	}
+	require.Nil(t, os.WriteFile(path, lines, 0666))
+
+	fileStorage, err := NewLocalFileStorage(path)
+	require.Nil(t, err)
+
+	stored, err := fileStorage.Read(ctx, "a0c7ecc8")
+	require.Nil(t, err)
+	assert.Equal(t, int64(2*compactionMinLines), stored.Clicks)
+
+	data, err := os.ReadFile(path)
+	require.Nil(t, err)
+	assert.Equal(t, 1, bytes.Count(data, []byte("\n")))
+
+	require.Nil(t, fileStorage.RegisterClick(ctx, "a0c7ecc8", commontypes.Click{Country: "KZ"}))
+	stored, err = fileStorage.Read(ctx, "a0c7ecc8")
+	require.Nil(t, err)
+	assert.Equal(t, int64(2*compactionMinLines+1), stored.Clicks)
+	assert.Equal(t, int64(1), stored.CountryClicks["KZ"])
+}
+
+func TestLocalFileStorageLongLines(t *testing.T) {
+	ctx := context.Background()
+
+	fileStorage, err := NewLocalFileStorage(filepath.Join(t.TempDir(), "links.json"))
+	require.Nil(t, err)
+
+	longURL := "https://practicum.yandex.kz/" + strings.Repeat("a", 100*1024)
+	require.Nil(t, fileStorage.Write(ctx, commontypes.URLRecord{ShortURLKey: "a0c7ecc8", FullURL: longURL}))
+
+	record, err := fileStorage.Read(ctx, "a0c7ecc8")
+	require.Nil(t, err)
+	assert.Equal(t, longURL, record.FullURL)
+}
+
+func TestLocalFileStorageList(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	fileStorage, err := NewLocalFileStorage(filepath.Join(t.TempDir(), "links.json"))
+	require.Nil(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.Nil(t, fileStorage.Write(ctx, commontypes.URLRecord{
+			ShortURLKey: fmt.Sprintf("key%d", i),
+			FullURL:     fmt.Sprintf("https://practicum.yandex.kz/%d", i),
+			CreatedAt:   createdAt.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	page, err := fileStorage.List(ctx, nil, 2)
+	require.Nil(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "key0", page[0].ShortURLKey)
+	assert.Equal(t, "key1", page[1].ShortURLKey)
+
+	// Writes after the first page are seen by the next ones.
+	require.Nil(t, fileStorage.Delete(ctx, "key2"))
+	require.Nil(t, fileStorage.Write(ctx, commontypes.URLRecord{
+		ShortURLKey: "key3",
+		FullURL:     "https://practicum.yandex.kz/3",
+		CreatedAt:   createdAt.Add(time.Hour),
+	}))
+	require.Nil(t, fileStorage.RegisterClick(ctx, "key3", commontypes.Click{}))
+
+	page, err = fileStorage.List(ctx, &commontypes.ListCursor{CreatedAt: page[1].CreatedAt, ShortURLKey: page[1].ShortURLKey}, 2)
+	require.Nil(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "key3", page[0].ShortURLKey)
+	assert.Equal(t, int64(1), page[0].Clicks)
+}
+
+func TestLocalFileStorageAuthSecret(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+
+	fileStorage, err := NewLocalFileStorage(path)
+	require.Nil(t, err)
+
+	secret, err := fileStorage.LoadOrStoreAuthSecret(ctx, "first")
+	require.Nil(t, err)
+	assert.Equal(t, "first", secret)
+
+	// The secret outlives the storage, like the links it protects.
+	fileStorage, err = NewLocalFileStorage(path)
+	require.Nil(t, err)
+
+	secret, err = fileStorage.LoadOrStoreAuthSecret(ctx, "second")
+	require.Nil(t, err)
+	assert.Equal(t, "first", secret)
+
+	info, err := os.Stat(path + ".secret")
+	require.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestLocalFileStorageUsageCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	today := time.Now().UTC().Format(time.DateOnly)
+
+	// Every new link of a user appends a new version of its counter.
+	var lines []byte
+	for i := 1; i <= 2*compactionMinLines; i++ {
+		for _, entry := range []localfile.LocalFileUsage{
+			{Subject: "user:a", Period: "2024-07-01", Used: int64(i)},
+			{Subject: "user:a", Period: today, Used: int64(i)},
+		} {
+			data, err := json.Marshal(entry)
+			require.Nil(t, err)
+			lines = append(append(lines, data...), '\n')
+		}
+	}
+	require.Nil(t, os.WriteFile(path+".usage", lines, 0666))
+
+	fileStorage, err := NewLocalFileStorage(path)
+	require.Nil(t, err)
+
+	used, err := fileStorage.AddUsage(ctx, "user:a", today, 1, 0)
+	require.Nil(t, err)
+	assert.Equal(t, int64(2*compactionMinLines+1), used)
+
+	// Counters of the past days are dropped.
+	data, err := os.ReadFile(path + ".usage")
+	require.Nil(t, err)
+	assert.Equal(t, 2, bytes.Count(data, []byte("\n")))
+
+	fileStorage, err = NewLocalFileStorage(path)
+	require.Nil(t, err)
+
+	used, err = fileStorage.ReadUsage(ctx, "user:a", today)
+	require.Nil(t, err)
+	assert.Equal(t, int64(2*compactionMinLines+1), used)
+}
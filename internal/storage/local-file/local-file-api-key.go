@@ -0,0 +1,41 @@
+package localfile
+
+import (
+	"time"
+
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+)
+
+type LocalFileAPIKey struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Name      string     `json:"name,omitempty"`
+	Hash      string     `json:"hash"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func NewLocalFileAPIKey(key commontypes.APIKey) *LocalFileAPIKey {
+	return &LocalFileAPIKey{
+		ID:        key.ID,
+		UserID:    key.UserID,
+		Name:      key.Name,
+		Hash:      key.Hash,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: optionalTime(key.RevokedAt),
+	}
+}
+
+func (key *LocalFileAPIKey) ToAPIKey() commontypes.APIKey {
+	return commontypes.APIKey{
+		ID:        key.ID,
+		UserID:    key.UserID,
+		Name:      key.Name,
+		Hash:      key.Hash,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: timeValue(key.RevokedAt),
+	}
+}
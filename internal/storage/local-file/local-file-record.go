@@ -1,17 +1,118 @@
 package localfile
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
+)
 
 type LocalFileRecord struct {
-	UUID        string `json:"uuid"`
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	UUID            string                     `json:"uuid"`
+	ShortURL        string                     `json:"short_url"`
+	OriginalURL     string                     `json:"original_url"`
+	CreatedAt       time.Time                  `json:"created_at"`
+	Clicks          int64                      `json:"clicks,omitempty"`
+	RedirectType    int                        `json:"redirect_type,omitempty"`
+	PassQuery       bool                       `json:"pass_query,omitempty"`
+	QueryPrecedence string                     `json:"query_precedence,omitempty"`
+	PassPath        bool                       `json:"pass_path,omitempty"`
+	UTM             map[string]string          `json:"utm,omitempty"`
+	DeviceRules     []commontypes.DeviceRule   `json:"device_rules,omitempty"`
+	Variants        []commontypes.Variant      `json:"variants,omitempty"`
+	VariantClicks   map[string]int64           `json:"variant_clicks,omitempty"`
+	GeoRules        []commontypes.GeoRule      `json:"geo_rules,omitempty"`
+	CountryClicks   map[string]int64           `json:"country_clicks,omitempty"`
+	PasswordHash    string                     `json:"password_hash,omitempty"`
+	MaxClicks       int64                      `json:"max_clicks,omitempty"`
+	ClicksLeft      int64                      `json:"clicks_left,omitempty"`
+	NotBefore       *time.Time                 `json:"not_before,omitempty"`
+	NotAfter        *time.Time                 `json:"not_after,omitempty"`
+	UserID          string                     `json:"user_id,omitempty"`
+	History         []commontypes.HistoryEntry `json:"history,omitempty"`
+	Title           string                     `json:"title,omitempty"`
+	Notes           string                     `json:"notes,omitempty"`
+	Tags            []string                   `json:"tags,omitempty"`
+	Alias           string                     `json:"alias,omitempty"`
+	Deleted         bool                       `json:"deleted,omitempty"` // tombstone of a deleted record
 }
 
-func NewLocalFileRecord(key string, value string) *LocalFileRecord {
+func NewLocalFileRecord(record commontypes.URLRecord) *LocalFileRecord {
+	createdAt := record.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
 	return &LocalFileRecord{
-		UUID:        uuid.New().String(),
-		ShortURL:    key,
-		OriginalURL: value,
+		UUID:            uuid.New().String(),
+		ShortURL:        record.ShortURLKey,
+		OriginalURL:     record.FullURL,
+		CreatedAt:       createdAt,
+		Clicks:          record.Clicks,
+		RedirectType:    record.RedirectType,
+		PassQuery:       record.PassQuery,
+		QueryPrecedence: record.QueryPrecedence,
+		PassPath:        record.PassPath,
+		UTM:             record.UTM,
+		DeviceRules:     record.DeviceRules,
+		Variants:        record.Variants,
+		VariantClicks:   record.VariantClicks,
+		GeoRules:        record.GeoRules,
+		CountryClicks:   record.CountryClicks,
+		PasswordHash:    record.PasswordHash,
+		MaxClicks:       record.MaxClicks,
+		ClicksLeft:      record.ClicksLeft,
+		NotBefore:       optionalTime(record.NotBefore),
+		NotAfter:        optionalTime(record.NotAfter),
+		UserID:          record.UserID,
+		Title:           record.Title,
+		Notes:           record.Notes,
+		Tags:            record.Tags,
+		Alias:           record.Alias,
+	}
+}
+
+func (record *LocalFileRecord) ToURLRecord() commontypes.URLRecord {
+	return commontypes.URLRecord{
+		ShortURLKey:   record.ShortURL,
+		FullURL:       record.OriginalURL,
+		UserID:        record.UserID,
+		CreatedAt:     record.CreatedAt,
+		Clicks:        record.Clicks,
+		Variants:      record.Variants,
+		VariantClicks: record.VariantClicks,
+		CountryClicks: record.CountryClicks,
+		PasswordHash:  record.PasswordHash,
+		ClicksLeft:    record.ClicksLeft,
+		LinkOptions: commontypes.LinkOptions{
+			RedirectType:    record.RedirectType,
+			PassQuery:       record.PassQuery,
+			QueryPrecedence: record.QueryPrecedence,
+			PassPath:        record.PassPath,
+			UTM:             record.UTM,
+			DeviceRules:     record.DeviceRules,
+			GeoRules:        record.GeoRules,
+			MaxClicks:       record.MaxClicks,
+			NotBefore:       timeValue(record.NotBefore),
+			NotAfter:        timeValue(record.NotAfter),
+			Title:           record.Title,
+			Notes:           record.Notes,
+			Tags:            record.Tags,
+			Alias:           record.Alias,
+		},
+	}
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func timeValue(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
 	}
+	return *t
 }
@@ -0,0 +1,7 @@
+package localfile
+
+type LocalFileUsage struct {
+	Subject string `json:"subject"`
+	Period  string `json:"period"`
+	Used    int64  `json:"used"`
+}
@@ -2,12 +2,61 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	commontypes "github.com/with0p/golang-url-shortener.git/internal/common-types"
 )
 
 type Storage interface {
-	Read(ctx context.Context, shortURLKey string) (string, error)
-	Write(ctx context.Context, shortURLKey string, fullURL string) error
-	WriteBatch(ctx context.Context, records []commontypes.BatchRecord) error
+	Read(ctx context.Context, shortURLKey string) (commontypes.URLRecord, error)
+	// ReadMany returns the links found among the keys by their key, in one go.
+	ReadMany(ctx context.Context, shortURLKeys []string) (map[string]commontypes.URLRecord, error)
+	// Write stores a new link, customerrors.ErrUniqueKeyConstrantViolation when its key
+	// is taken.
+	Write(ctx context.Context, record commontypes.URLRecord) error
+	// WriteBatch stores all the links as Write does or, when one of their keys is taken,
+	// none of them with customerrors.ErrUniqueKeyConstrantViolation.
+	WriteBatch(ctx context.Context, records []commontypes.URLRecord) error
+	RegisterClick(ctx context.Context, shortURLKey string, click commontypes.Click) error
+	SetVariants(ctx context.Context, shortURLKey string, variants []commontypes.Variant) error
+	// ConsumeClick atomically takes one of the clicks left of a click limited link and
+	// returns how many remain, customerrors.ErrLinkExhausted when there are none.
+	ConsumeClick(ctx context.Context, shortURLKey string) (int64, error)
+	// UpdateURL changes the destination of the link and records the change in its history.
+	UpdateURL(ctx context.Context, shortURLKey string, fullURL string, editor string) (commontypes.HistoryEntry, error)
+	ReadHistory(ctx context.Context, shortURLKey string) ([]commontypes.HistoryEntry, error)
+	// ListByUser returns up to query.Limit links of the user matching the query, sorted by
+	// query.Sort and then by short URL.
+	ListByUser(ctx context.Context, userID string, query commontypes.ListQuery) ([]commontypes.URLRecord, error)
+	// List returns up to limit links of all users created after the cursor, ordered by
+	// creation time and then by short URL.
+	List(ctx context.Context, after *commontypes.ListCursor, limit int) ([]commontypes.URLRecord, error)
+	// Delete removes the link with its history, customerrors.ErrNotFound when there is none.
+	Delete(ctx context.Context, shortURLKey string) error
+	Stats(ctx context.Context) (commontypes.Stats, error)
+
+	// AddUsage adds n to the usage of the subject over the period and returns the new
+	// usage, customerrors.ErrQuotaExceeded when it would go over limit. A non-positive
+	// limit means there is none.
+	AddUsage(ctx context.Context, subject string, period string, n int64, limit int64) (int64, error)
+	ReadUsage(ctx context.Context, subject string, period string) (int64, error)
+	// CountActiveURLs counts the links of the user that have neither expired nor run
+	// out of clicks.
+	CountActiveURLs(ctx context.Context, userID string, now time.Time) (int64, error)
+	// ReserveActiveURLs holds n more active links of the user, checking in one step that
+	// its active links and those already held stay within limit, or returns
+	// customerrors.ErrQuotaExceeded. A negative n gives held links back.
+	ReserveActiveURLs(ctx context.Context, userID string, n int64, limit int64, now time.Time) error
+
+	WriteAPIKey(ctx context.Context, key commontypes.APIKey) error
+	// ReadAPIKey finds the key by its hash, customerrors.ErrNotFound when there is none.
+	ReadAPIKey(ctx context.Context, hash string) (commontypes.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]commontypes.APIKey, error)
+	// RevokeAPIKey revokes the key of the user, customerrors.ErrNotFound when the user
+	// has no such key.
+	RevokeAPIKey(ctx context.Context, userID string, id string, revokedAt time.Time) error
+
+	// LoadOrStoreAuthSecret returns the secret signing the user cookies, storing the
+	// given one first when there is none yet.
+	LoadOrStoreAuthSecret(ctx context.Context, secret string) (string, error)
 }
@@ -0,0 +1,47 @@
+package trustedsubnet
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/with0p/golang-url-shortener.git/internal/clientip"
+	"github.com/with0p/golang-url-shortener.git/internal/logger"
+)
+
+// Guard lets through the requests of clients inside the trusted subnet only.
+type Guard struct {
+	subnet   *net.IPNet
+	resolver *clientip.Resolver
+}
+
+// NewGuard trusts the subnet in CIDR notation. The client address is taken from the
+// resolver, so forwarding headers only count when set by a trusted proxy. Without a
+// valid subnet every request is forbidden.
+func NewGuard(cidr string, resolver *clientip.Resolver) *Guard {
+	guard := &Guard{resolver: resolver}
+
+	if cidr = strings.TrimSpace(cidr); cidr != "" {
+		_, subnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			logger.LogError(err)
+		}
+		guard.subnet = subnet
+	}
+
+	return guard
+}
+
+func (guard *Guard) HandleWithTrustedSubnet(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !guard.IsTrusted(guard.resolver.ClientIP(r)) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (guard *Guard) IsTrusted(ip net.IP) bool {
+	return guard.subnet != nil && ip != nil && guard.subnet.Contains(ip)
+}
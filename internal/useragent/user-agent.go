@@ -0,0 +1,87 @@
+package useragent
+
+import "strings"
+
+const (
+	OSiOS      = "ios"
+	OSAndroid  = "android"
+	OSWindows  = "windows"
+	OSMacOS    = "macos"
+	OSChromeOS = "chromeos"
+	OSLinux    = "linux"
+	OSOther    = "other"
+)
+
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+)
+
+var OSes = []string{OSiOS, OSAndroid, OSWindows, OSMacOS, OSChromeOS, OSLinux, OSOther}
+
+var Devices = []string{DeviceMobile, DeviceTablet, DeviceDesktop}
+
+var botMarkers = []string{
+	"bot", "crawl", "spider", "slurp", "preview", "facebookexternalhit", "whatsapp",
+	"curl/", "wget/", "python-requests", "go-http-client", "headless",
+}
+
+type UserAgent struct {
+	OS     string
+	Device string
+	Bot    bool
+}
+
+// Parse recognizes the operating system, the device class and bots from the
+// User-Agent header. It only looks for well known markers and never fails.
+func Parse(header string) UserAgent {
+	ua := strings.ToLower(header)
+
+	return UserAgent{
+		OS:     parseOS(ua),
+		Device: parseDevice(ua),
+		Bot:    header == "" || containsAny(ua, botMarkers),
+	}
+}
+
+func parseOS(ua string) string {
+	switch {
+	case containsAny(ua, []string{"iphone", "ipad", "ipod"}):
+		return OSiOS
+	case strings.Contains(ua, "android"):
+		return OSAndroid
+	case strings.Contains(ua, "windows"):
+		return OSWindows
+	case strings.Contains(ua, "cros"):
+		return OSChromeOS
+	case containsAny(ua, []string{"macintosh", "mac os x"}):
+		return OSMacOS
+	case strings.Contains(ua, "linux"):
+		return OSLinux
+	default:
+		return OSOther
+	}
+}
+
+func parseDevice(ua string) string {
+	switch {
+	case containsAny(ua, []string{"ipad", "tablet"}):
+		return DeviceTablet
+	case strings.Contains(ua, "android") && !strings.Contains(ua, "mobile"):
+		return DeviceTablet
+	case containsAny(ua, []string{"iphone", "ipod", "mobile"}):
+		return DeviceMobile
+	default:
+		return DeviceDesktop
+	}
+}
+
+func containsAny(str string, markers []string) bool {
+	for _, marker := range markers {
+		if strings.Contains(str, marker) {
+			return true
+		}
+	}
+	return false
+}
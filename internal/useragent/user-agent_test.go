@@ -0,0 +1,62 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected UserAgent
+	}{
+		{
+			name:     "Check iPhone",
+			header:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1",
+			expected: UserAgent{OS: OSiOS, Device: DeviceMobile},
+		},
+		{
+			name:     "Check iPad",
+			header:   "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			expected: UserAgent{OS: OSiOS, Device: DeviceTablet},
+		},
+		{
+			name:     "Check Android phone",
+			header:   "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36",
+			expected: UserAgent{OS: OSAndroid, Device: DeviceMobile},
+		},
+		{
+			name:     "Check Android tablet",
+			header:   "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+			expected: UserAgent{OS: OSAndroid, Device: DeviceTablet},
+		},
+		{
+			name:     "Check Windows desktop",
+			header:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+			expected: UserAgent{OS: OSWindows, Device: DeviceDesktop},
+		},
+		{
+			name:     "Check macOS desktop",
+			header:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15",
+			expected: UserAgent{OS: OSMacOS, Device: DeviceDesktop},
+		},
+		{
+			name:     "Check search bot",
+			header:   "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			expected: UserAgent{OS: OSOther, Device: DeviceDesktop, Bot: true},
+		},
+		{
+			name:     "Check empty header",
+			header:   "",
+			expected: UserAgent{OS: OSOther, Device: DeviceDesktop, Bot: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Parse(tt.header))
+		})
+	}
+}